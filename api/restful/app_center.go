@@ -14,6 +14,7 @@ limitations under the License.
 package restful
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"strings"
@@ -24,8 +25,8 @@ import (
 	"github.com/quanxiang-cloud/appcenter/internal/req"
 	resp2 "github.com/quanxiang-cloud/appcenter/internal/resp"
 	config2 "github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	"github.com/quanxiang-cloud/cabin/logger"
-	header2 "github.com/quanxiang-cloud/cabin/tailormade/header"
 	"github.com/quanxiang-cloud/cabin/tailormade/resp"
 	"gorm.io/gorm"
 )
@@ -58,7 +59,7 @@ func NewAppCenter(c *config2.Configs, db *gorm.DB) (*AppCenter, error) {
 
 // Add create a app
 func (a *AppCenter) Add(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.AddAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -71,10 +72,48 @@ func (a *AppCenter) Add(c *gin.Context) {
 	resp.Format(a.appCenter.Add(ctx, rq)).Context(c)
 }
 
+// AddStream create a app, and stream the progress of its initialization over server-sent events,
+// the stream ends with an async event if the initialization is not finished in time.
+func (a *AppCenter) AddStream(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.AddAppCenter{}
+	err := c.ShouldBind(&rq)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	rq.CreateBy = c.GetHeader(_userID)
+	rq.CreateByName = c.GetHeader(_userName)
+	center, err := a.appCenter.Add(ctx, rq)
+	if err != nil {
+		resp.Format(center, err).Context(c)
+		return
+	}
+
+	// the watch stops when the client goes away
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.Request.Context().Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	c.SSEvent("app", center)
+	c.Writer.Flush()
+	for e := range a.appCenter.WatchInit(ctx, center.ID) {
+		c.SSEvent(e.Type, e)
+		c.Writer.Flush()
+	}
+}
+
 // Update update the app information
 func (a *AppCenter) Update(c *gin.Context) {
 
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.UpdateAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -106,7 +145,7 @@ func (a *AppCenter) Update(c *gin.Context) {
 
 // AdminList manager get the app list
 func (a *AppCenter) AdminList(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.SelectListAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -128,7 +167,7 @@ func (a *AppCenter) AdminList(c *gin.Context) {
 
 // SuperAdminList super manger get the app list
 func (a *AppCenter) SuperAdminList(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.SelectListAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -150,7 +189,7 @@ func (a *AppCenter) SuperAdminList(c *gin.Context) {
 
 // One find one app
 func (a *AppCenter) One(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.SelectOneAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -180,7 +219,7 @@ func (a *AppCenter) One(c *gin.Context) {
 
 // AddAdmin add the app manager
 func (a *AppCenter) AddAdmin(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.AddAdminUser{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -210,7 +249,7 @@ func (a *AppCenter) AddAdmin(c *gin.Context) {
 
 // DelAdmin remove app manager
 func (a *AppCenter) DelAdmin(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.DelAdminUser{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -239,7 +278,7 @@ func (a *AppCenter) DelAdmin(c *gin.Context) {
 
 // Del delete the application
 func (a *AppCenter) Del(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.DelAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -258,6 +297,7 @@ func (a *AppCenter) Del(c *gin.Context) {
 		resp.Format(nil, nil).Context(c, http.StatusForbidden)
 		return
 	}
+	rq.DeleteBy = c.GetHeader(_userID)
 	err = a.appCenter.Delete(ctx, &rq)
 	if err != nil {
 		logger.Logger.Error(err)
@@ -269,7 +309,7 @@ func (a *AppCenter) Del(c *gin.Context) {
 
 // UpdateStatus modify the app status
 func (a *AppCenter) UpdateStatus(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := req.UpdateAppCenter{}
 	err := c.ShouldBind(&rq)
@@ -302,7 +342,7 @@ func (a *AppCenter) UpdateStatus(c *gin.Context) {
 
 // UserList user get the app list on home platform
 func (a *AppCenter) UserList(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.SelectListAppCenter{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -325,7 +365,7 @@ func (a *AppCenter) UserList(c *gin.Context) {
 
 // AdminUsers get the admin list
 func (a *AppCenter) AdminUsers(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.SelectAdminUsers{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -356,7 +396,7 @@ func (a *AppCenter) AdminUsers(c *gin.Context) {
 
 // GetAppsByIDs GetAppsByIDs
 func (a *AppCenter) GetAppsByIDs(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.GetAppsByIDsReq{}
 	err := c.ShouldBind(&rq)
 	if err != nil {
@@ -393,7 +433,7 @@ func (a *AppCenter) CheckIsAdmin(c *gin.Context) {
 
 // AddAppScope AddAppScope
 func (a *AppCenter) AddAppScope(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	req := &req.AddAppScopeReq{}
 	if err := c.ShouldBind(req); err != nil {
 		logger.Logger.Error(err)
@@ -406,7 +446,7 @@ func (a *AppCenter) AddAppScope(c *gin.Context) {
 
 // GetOne GetOne
 func (a *AppCenter) GetOne(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	req := &req.GetOneReq{}
 	if err := c.ShouldBind(req); err != nil {
 		logger.Logger.Error(err)
@@ -419,7 +459,7 @@ func (a *AppCenter) GetOne(c *gin.Context) {
 
 // CheckVersion CheckVersion
 func (a *AppCenter) CheckVersion(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.CheckImportVersionReq{}
 	if err := c.ShouldBind(rq); err != nil {
 		logger.Logger.Error(err)
@@ -431,7 +471,7 @@ func (a *AppCenter) CheckVersion(c *gin.Context) {
 
 // CreateImportApp CreateImportApp
 func (a *AppCenter) CreateImportApp(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := &req.AddAppCenter{}
 	if err := c.ShouldBind(rq); err != nil {
@@ -446,7 +486,7 @@ func (a *AppCenter) CreateImportApp(c *gin.Context) {
 
 // SuccessImport SuccessImport
 func (a *AppCenter) SuccessImport(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := &req.FinishImportReq{}
 	if err := c.ShouldBind(rq); err != nil {
@@ -461,7 +501,7 @@ func (a *AppCenter) SuccessImport(c *gin.Context) {
 
 // FailImport FailImport
 func (a *AppCenter) FailImport(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := &req.ErrorImportReq{}
 	if err := c.ShouldBind(rq); err != nil {
@@ -475,7 +515,7 @@ func (a *AppCenter) FailImport(c *gin.Context) {
 
 // ExportApp ExportApp
 func (a *AppCenter) ExportApp(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.ExportAppReq{}
 	if err := c.ShouldBind(rq); err != nil {
 		logger.Logger.Error(err)
@@ -487,7 +527,7 @@ func (a *AppCenter) ExportApp(c *gin.Context) {
 
 //CheckAppAccess CheckAppAccess
 func (a *AppCenter) CheckAppAccess(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.CheckAppAccessReq{}
 	if err := c.ShouldBind(rq); err != nil {
 		logger.Logger.Error(err)
@@ -513,9 +553,17 @@ func isSuperRole(c *gin.Context) bool {
 	return false
 }
 
+// mutateContext return the context of the request carrying the actor of the audits
+func mutateContext(c *gin.Context) context.Context {
+	return logic.WithActor(tracing.MutateContext(c), logic.Actor{
+		ID:   c.GetHeader(_userID),
+		Name: c.GetHeader(_userName),
+	})
+}
+
 // InitCallBack call back
 func (a *AppCenter) InitCallBack(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.InitCallBackReq{}
 	if err := c.ShouldBind(rq); err != nil {
 		logger.Logger.Error(err)
@@ -529,7 +577,7 @@ func (a *AppCenter) InitCallBack(c *gin.Context) {
 
 // InitServer init
 func (a *AppCenter) InitServer(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.InitServerReq{}
 	if err := c.ShouldBind(rq); err != nil {
 		logger.Logger.Error(err)
@@ -542,7 +590,7 @@ func (a *AppCenter) InitServer(c *gin.Context) {
 
 // ListAppByStatus ListAppByStatus
 func (a *AppCenter) ListAppByStatus(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := req.ListAppByStatusReq{}
 	if err := c.ShouldBind(&rq); err != nil {
@@ -553,8 +601,29 @@ func (a *AppCenter) ListAppByStatus(c *gin.Context) {
 	resp.Format(a.appCenter.ListAppByStatus(ctx, &rq)).Context(c)
 }
 
+// StatusHistory list the transitions of the use status of the app
+func (a *AppCenter) StatusHistory(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := req.StatusHistoryReq{}
+	if err := c.ShouldBind(&rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	isAdminReq := &req.CheckIsAdminReq{
+		AppID:   rq.AppID,
+		UserID:  c.GetHeader(_userID),
+		IsSuper: isSuperRole(c),
+	}
+	if !a.appCenter.CheckIsAdmin(ctx, isAdminReq) {
+		resp.Format(nil, nil).Context(c, http.StatusForbidden)
+		return
+	}
+	resp.Format(a.appCenter.StatusHistory(ctx, &rq)).Context(c)
+}
+
 func (a *AppCenter) ChangePerPoly(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 
 	rq := req.ChangePerPolyReq{}
 	if err := c.ShouldBind(&rq); err != nil {
@@ -566,7 +635,7 @@ func (a *AppCenter) ChangePerPoly(c *gin.Context) {
 }
 
 func (a *AppCenter) HomeAccessUpdate(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.AddAppScopeReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -577,7 +646,7 @@ func (a *AppCenter) HomeAccessUpdate(c *gin.Context) {
 }
 
 func (a *AppCenter) HomeAccessList(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := req.HomeAccessListReq{}
 	rq.AppID = c.Param("appID")
 	if err := c.ShouldBind(&rq); err != nil {
@@ -587,3 +656,76 @@ func (a *AppCenter) HomeAccessList(c *gin.Context) {
 	}
 	resp.Format(a.appCenter.HomeAccessList(ctx, &rq)).Context(c)
 }
+
+// RecycleList get the apps in the recycle bin
+func (a *AppCenter) RecycleList(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := req.SelectRecycleList{}
+	err := c.ShouldBind(&rq)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	rq.UserID = c.GetHeader(_userID)
+	rq.IsSuper = isSuperRole(c)
+
+	res, err := a.appCenter.RecyclePageList(ctx, &rq)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	resp.Format(res, nil).Context(c)
+}
+
+// Restore take the app out of the recycle bin
+func (a *AppCenter) Restore(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := req.RestoreAppReq{}
+	err := c.ShouldBind(&rq)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	rq.UpdateBy = c.GetHeader(_userID)
+
+	isAdminReq := &req.CheckIsAdminReq{
+		AppID:   rq.ID,
+		UserID:  c.GetHeader(_userID),
+		IsSuper: isSuperRole(c),
+		Deleted: true,
+	}
+	isAdmin := a.appCenter.CheckIsAdmin(ctx, isAdminReq)
+	if !isAdmin {
+		resp.Format(nil, nil).Context(c, http.StatusForbidden)
+		return
+	}
+	resp.Format(a.appCenter.Restore(ctx, &rq)).Context(c)
+}
+
+// Purge purge the app in the recycle bin without waiting for the delete time
+func (a *AppCenter) Purge(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := req.PurgeAppReq{}
+	err := c.ShouldBind(&rq)
+	if err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+
+	isAdminReq := &req.CheckIsAdminReq{
+		AppID:   rq.ID,
+		UserID:  c.GetHeader(_userID),
+		IsSuper: isSuperRole(c),
+		Deleted: true,
+	}
+	isAdmin := a.appCenter.CheckIsAdmin(ctx, isAdminReq)
+	if !isAdmin {
+		resp.Format(nil, nil).Context(c, http.StatusForbidden)
+		return
+	}
+	resp.Format(a.appCenter.PurgeNow(ctx, &rq)).Context(c)
+}
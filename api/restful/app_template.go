@@ -22,7 +22,6 @@ import (
 	"github.com/quanxiang-cloud/appcenter/internal/req"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
 	"github.com/quanxiang-cloud/cabin/logger"
-	header2 "github.com/quanxiang-cloud/cabin/tailormade/header"
 	"github.com/quanxiang-cloud/cabin/tailormade/resp"
 	"gorm.io/gorm"
 )
@@ -41,7 +40,7 @@ func NewTemplate(conf *config.Configs, db *gorm.DB) *Template {
 
 // Create create template
 func (t *Template) Create(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.CreateTemplateReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -55,7 +54,7 @@ func (t *Template) Create(c *gin.Context) {
 
 // Delete delete by id
 func (t *Template) Delete(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.DeleteTemplateReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -68,7 +67,7 @@ func (t *Template) Delete(c *gin.Context) {
 
 // ToPublic make the template to public
 func (t *Template) ToPublic(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.ModifyStatusReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -82,7 +81,7 @@ func (t *Template) ToPublic(c *gin.Context) {
 
 // ToPrivate make the template to private
 func (t *Template) ToPrivate(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.ModifyStatusReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -96,7 +95,7 @@ func (t *Template) ToPrivate(c *gin.Context) {
 
 // GetSelfTemplate get user's template
 func (t *Template) GetSelfTemplate(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.GetSelfTemplateReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -109,7 +108,7 @@ func (t *Template) GetSelfTemplate(c *gin.Context) {
 
 // GetTemplateByID GetTemplateByID
 func (t *Template) GetTemplateByID(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.GetTemplateByIDReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -121,7 +120,7 @@ func (t *Template) GetTemplateByID(c *gin.Context) {
 
 // GetTemplateByPage GetTemplateByPage
 func (t *Template) GetTemplateByPage(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.GetTemplateByPageReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -133,7 +132,7 @@ func (t *Template) GetTemplateByPage(c *gin.Context) {
 
 // CheckNameRepeat CheckNameRepeat
 func (t *Template) CheckNameRepeat(c *gin.Context) {
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq := &req.CheckNameRepeatReq{}
 	if err := c.ShouldBind(&rq); err != nil {
 		logger.Logger.Error(err)
@@ -151,7 +150,7 @@ func (t *Template) ModifyTemplate(c *gin.Context) {
 		_ = c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq.UserID = c.GetHeader(_userID)
 	rq.UserName = c.GetHeader(_userName)
 	resp.Format(t.template.ModifyTemplate(ctx, rq)).Context(c)
@@ -165,7 +164,7 @@ func (t *Template) FinishCreating(c *gin.Context) {
 		_ = c.AbortWithError(http.StatusInternalServerError, err)
 		return
 	}
-	ctx := header2.MutateContext(c)
+	ctx := mutateContext(c)
 	rq.UserID = c.GetHeader(_userID)
 	rq.UserName = c.GetHeader(_userName)
 	resp.Format(t.template.FinishCreating(ctx, rq)).Context(c)
@@ -0,0 +1,70 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/logic/app"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/cabin/logger"
+	"github.com/quanxiang-cloud/cabin/tailormade/resp"
+	"gorm.io/gorm"
+)
+
+// Audit the audit log
+type Audit struct {
+	audit logic.Audit
+}
+
+// NewAudit new audit
+func NewAudit(db *gorm.DB) *Audit {
+	return &Audit{
+		audit: app.NewAudit(db),
+	}
+}
+
+// List list the audits by page
+func (a *Audit) List(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.ListAuditReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	resp.Format(a.audit.List(ctx, rq)).Context(c)
+}
+
+// Export export the audits as csv
+func (a *Audit) Export(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.ListAuditReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit-%d.csv", time.Now().Unix()))
+	c.Status(http.StatusOK)
+	if err := a.audit.Export(ctx, rq, c.Writer); err != nil {
+		// the header is written already, the export is truncated
+		logger.Logger.Error("export audit is error ", err.Error())
+	}
+}
@@ -14,20 +14,27 @@ limitations under the License.
 package restful
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/appcenter/internal/logic/app"
 	"github.com/quanxiang-cloud/appcenter/pkg/broker"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos"
 	exec "github.com/quanxiang-cloud/appcenter/pkg/chaos/executor"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
+	client2 "github.com/quanxiang-cloud/appcenter/pkg/client"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/metrics"
 	"github.com/quanxiang-cloud/appcenter/pkg/probe"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	"github.com/quanxiang-cloud/cabin/logger"
 	"github.com/quanxiang-cloud/cabin/tailormade/client"
 	"github.com/quanxiang-cloud/cabin/tailormade/db/mysql"
 	ginlog "github.com/quanxiang-cloud/cabin/tailormade/gin"
+	"github.com/quanxiang-cloud/cabin/tailormade/resp"
 )
 
 const (
@@ -35,6 +42,8 @@ const (
 	DebugMode = "debug"
 	// ReleaseMode indicates mode is release.
 	ReleaseMode = "release"
+
+	defaultShutdownTimeout = 10
 )
 
 // Router router
@@ -42,7 +51,10 @@ type Router struct {
 	c *config.Configs
 
 	engine *gin.Engine
+	server *http.Server
 	Probe  *probe.Probe
+
+	cancel context.CancelFunc
 }
 
 // NewRouter open the router
@@ -57,44 +69,61 @@ func NewRouter(c *config.Configs, log logger.AdaptedLogger) (*Router, error) {
 	if err != nil {
 		return nil, err
 	}
-	app, err := NewAppCenter(c, db)
+	if err := db.Use(tracing.Gorm()); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go app.NewPurger(c, db).Run(ctx)
+	go app.NewRelay(c, db).Run(ctx)
+	go app.NewDispatcher(c, db).Run(ctx)
+	go app.NewScheduler(c, db).Run(ctx)
+
+	appCenter, err := NewAppCenter(c, db)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	k := v1.Group("")
 	{
-		k.POST("/homeAccess/update/owner", app.HomeAccessUpdate)
-
-		k.POST("/homeAccess/:appID", app.HomeAccessList)
-		k.POST("/add", app.Add)
-		k.POST("/update", app.Update)
-		k.POST("/adminList", checkIsSuperAdmin(app.AdminList, app.SuperAdminList))
-		k.POST("/one", app.One)
-		k.POST("/addAdmin", app.AddAdmin)
-		k.POST("/delAdmin", app.DelAdmin)
-		k.POST("/del", app.Del)
-		k.POST("/updateStatus", app.UpdateStatus)
-		k.POST("/adminUsers", app.AdminUsers)
-		k.POST("/checkIsAdmin", app.CheckIsAdmin)
-		k.POST("/checkAppAccess", app.CheckAppAccess)
-		k.POST("/perPoly", app.ChangePerPoly)
+		k.POST("/homeAccess/update/owner", appCenter.HomeAccessUpdate)
+
+		k.POST("/homeAccess/:appID", appCenter.HomeAccessList)
+		k.POST("/add", appCenter.Add)
+		k.POST("/add/stream", appCenter.AddStream)
+		k.POST("/update", appCenter.Update)
+		k.POST("/adminList", checkIsSuperAdmin(appCenter.AdminList, appCenter.SuperAdminList))
+		k.POST("/one", appCenter.One)
+		k.POST("/addAdmin", appCenter.AddAdmin)
+		k.POST("/delAdmin", appCenter.DelAdmin)
+		k.POST("/del", appCenter.Del)
+		k.POST("/updateStatus", appCenter.UpdateStatus)
+		k.POST("/adminUsers", appCenter.AdminUsers)
+		k.POST("/checkIsAdmin", appCenter.CheckIsAdmin)
+		k.POST("/checkAppAccess", appCenter.CheckAppAccess)
+		k.POST("/perPoly", appCenter.ChangePerPoly)
+		k.POST("/statusHistory", appCenter.StatusHistory)
+
+		//----------------------recycle bin--------------------
+		k.POST("/recycle/list", appCenter.RecycleList)
+		k.POST("/recycle/restore", appCenter.Restore)
+		k.POST("/recycle/purge", appCenter.Purge)
 
 		//----------------------home platform--------------------
-		k.POST("/userList", app.UserList)
-		k.POST("/apps", app.GetAppsByIDs)
+		k.POST("/userList", appCenter.UserList)
+		k.POST("/apps", appCenter.GetAppsByIDs)
 
 		// -----------------provide services for other services-----------------
-		k.POST("/addAppScope", app.AddAppScope)
-		k.POST("/getOne", app.GetOne)
-		k.POST("/successImport", app.SuccessImport)
-		k.POST("/failImport", app.FailImport)
-		k.POST("/checkVersion", app.CheckVersion)
-		k.POST("/exportApp", app.ExportApp)
-		k.POST("/importApp", app.CreateImportApp)
-		k.POST("/initCallBack", app.InitCallBack)
-		k.POST("/initServer", app.InitServer)
-		k.POST("/listAppByStatus", app.ListAppByStatus)
+		k.POST("/addAppScope", appCenter.AddAppScope)
+		k.POST("/getOne", appCenter.GetOne)
+		k.POST("/successImport", appCenter.SuccessImport)
+		k.POST("/failImport", appCenter.FailImport)
+		k.POST("/checkVersion", appCenter.CheckVersion)
+		k.POST("/exportApp", appCenter.ExportApp)
+		k.POST("/importApp", appCenter.CreateImportApp)
+		k.POST("/initCallBack", appCenter.InitCallBack)
+		k.POST("/initServer", appCenter.InitServer)
+		k.POST("/listAppByStatus", appCenter.ListAppByStatus)
 
 	}
 
@@ -114,10 +143,54 @@ func NewRouter(c *config.Configs, log logger.AdaptedLogger) (*Router, error) {
 		t.POST("/finish", template.FinishCreating)
 	}
 
+	webhook := NewWebhook(c, db, appCenter)
+	w := v1.Group("/webhook")
+	{
+		w.POST("/create", webhook.Create)
+		w.POST("/update", webhook.Update)
+		w.POST("/delete", webhook.Delete)
+		w.POST("/list", webhook.List)
+		w.POST("/deliveries", webhook.Deliveries)
+		w.POST("/test", webhook.Test)
+	}
+
+	schedule := NewSchedule(db, appCenter)
+	sc := v1.Group("/schedule")
+	{
+		sc.POST("/create", schedule.Create)
+		sc.POST("/list", schedule.List)
+		sc.POST("/cancel", schedule.Cancel)
+	}
+
+	audit := NewAudit(db)
+	au := v1.Group("/audit")
+	{
+		au.POST("/list", onlySuperAdmin(audit.List))
+		au.POST("/export", onlySuperAdmin(audit.Export))
+	}
+
 	r := &Router{
 		c:      c,
 		engine: engine,
-		Probe:  probe.New(),
+		server: newServer(c, engine),
+		Probe:  newProbe(c),
+		cancel: cancel,
+	}
+	r.Probe.AddChecker(probe.DB(db), probe.Redis(redis2.ClusterClient))
+	cli := client.New(c.InternalNet)
+	r.Probe.AddOptional(probe.HTTP("chaos", client2.ChaosLivenessURL, &cli))
+	if c.Probe.Downstream {
+		hosts := []struct{ name, url string }{
+			{"structor", c.InnerHost.StructorHost},
+			{"flow", c.InnerHost.FlowHost},
+			{"polyapi", c.InnerHost.PolyAPI},
+			{"org", c.InnerHost.OrgHost},
+		}
+		for _, host := range hosts {
+			if host.url != "" {
+				r.Probe.AddOptional(probe.HTTP(host.name, host.url, &cli))
+			}
+		}
 	}
 	r.probe()
 	return r, nil
@@ -130,30 +203,26 @@ func NewInitRouter(c *config.Configs, b *broker.Broker, log logger.AdaptedLogger
 		return nil, err
 	}
 
+	executors, err := exec.Build(c)
+	if err != nil {
+		return nil, err
+	}
 	handler, err := handle.New(c, b, log)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: set executors
-	handler.SetTaskExecutors(&exec.FormExecutor{
-		Client:     client.New(c.InternalNet),
-		CreateRole: c.KV[exec.FormCreateRole],
-		AssignRole: c.KV[exec.FormAssignRole],
-	}, &exec.PolyExecutor{
-		Client:  client.New(c.InternalNet),
-		PolyURL: c.KV[exec.PolyInit],
-	})
+	handler.SetTaskExecutors(executors...)
 
 	handler.SetInitExecutors(exec.InitExec)
 	handler.SetSuccessExecutors(&exec.SuccessExecutor{
 		BaseExecutor: exec.BaseExecutor{
-			Client:       client.New(c.InternalNet),
+			Client:       client2.NewHTTPClient(c.InternalNet),
 			AppCenterURL: c.KV[exec.InitBack],
 		},
 	})
 	handler.SetFailureExecutors(&exec.FailureExecutor{
 		BaseExecutor: exec.BaseExecutor{
-			Client:       client.New(c.InternalNet),
+			Client:       client2.NewHTTPClient(c.InternalNet),
 			AppCenterURL: c.KV[exec.InitBack],
 		},
 	})
@@ -163,11 +232,32 @@ func NewInitRouter(c *config.Configs, b *broker.Broker, log logger.AdaptedLogger
 		return nil, err
 	}
 	engine.POST("/init", p.Handle)
+	engine.GET("/tasks", p.Tasks)
+	engine.GET("/tasks/:appID", p.Tasks)
+	engine.GET("/events/:appID", p.Events)
+	engine.GET("/deadLetters", p.DeadLetters)
+	engine.GET("/deadLetters/:id", p.DeadLetter)
+	engine.POST("/deadLetters/:id/replay", p.Replay)
+	engine.DELETE("/deadLetters/:id", p.Discard)
 
-	return &Router{
+	r := &Router{
 		c:      c,
 		engine: engine,
-	}, nil
+		server: newServer(c, engine),
+		Probe:  newProbe(c),
+	}
+	r.Probe.AddChecker(probe.CheckFunc("queue", handler.Ping))
+	if c.Chaos.Queue.Backend == handle.QueueRedis {
+		r.Probe.AddChecker(probe.Redis(redis2.ClusterClient))
+	}
+	r.probe()
+	return r, nil
+}
+
+func newProbe(c *config.Configs) *probe.Probe {
+	p := probe.New()
+	p.SetTimeout(c.Probe.Timeout * time.Second)
+	return p
 }
 
 func newRouter(c *config.Configs) (*gin.Engine, error) {
@@ -176,7 +266,8 @@ func newRouter(c *config.Configs) (*gin.Engine, error) {
 	}
 	gin.SetMode(c.Model)
 	engine := gin.New()
-	engine.Use(ginlog.LoggerFunc(), ginlog.LoggerFunc())
+	engine.Use(ginlog.LoggerFunc(), tracing.Gin("/metrics", "/liveness", "/readiness"), metrics.Gin())
+	engine.GET("/metrics", metrics.Handler())
 	return engine, nil
 }
 
@@ -190,23 +281,40 @@ func (r *Router) probe() {
 	})
 }
 
-// Run start server
-func (r *Router) Run() {
-	s := &http.Server{
-		Addr:              ":" + r.c.HTTPServer.Port,
-		Handler:           r.engine,
-		ReadHeaderTimeout: r.c.HTTPServer.ReadHeaderTimeOut * time.Second,
-		WriteTimeout:      r.c.HTTPServer.WriteTimeOut * time.Second,
-		MaxHeaderBytes:    r.c.HTTPServer.MaxHeaderBytes,
-	}
-	err := s.ListenAndServe()
-	if err != nil {
-		panic(err)
+// Run start server, it returns nil after Close
+func (r *Router) Run() error {
+	err := r.server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		return err
 	}
+	return nil
 }
 
-// Close close server
+// Close stop accepting requests and wait for the requests in progress
 func (r *Router) Close() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	timeout := r.c.HTTPServer.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
+	defer cancel()
+	if err := r.server.Shutdown(ctx); err != nil {
+		logger.Logger.Errorf("shutdown http server is error %s", err.Error())
+	}
+}
+
+func newServer(c *config.Configs, engine *gin.Engine) *http.Server {
+	return &http.Server{
+		Addr:              ":" + c.HTTPServer.Port,
+		Handler:           engine,
+		ReadHeaderTimeout: c.HTTPServer.ReadHeaderTimeOut * time.Second,
+		WriteTimeout:      c.HTTPServer.WriteTimeOut * time.Second,
+		MaxHeaderBytes:    c.HTTPServer.MaxHeaderBytes,
+	}
 }
 
 func checkIsSuperAdmin(funcAdmin, funcSuperAdmin func(c *gin.Context)) func(c *gin.Context) {
@@ -219,3 +327,13 @@ func checkIsSuperAdmin(funcAdmin, funcSuperAdmin func(c *gin.Context)) func(c *g
 
 	}
 }
+
+func onlySuperAdmin(funcSuperAdmin func(c *gin.Context)) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !isSuperRole(c) {
+			resp.Format(nil, nil).Context(c, http.StatusForbidden)
+			return
+		}
+		funcSuperAdmin(c)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/logic/app"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/cabin/logger"
+	"github.com/quanxiang-cloud/cabin/tailormade/resp"
+	"gorm.io/gorm"
+)
+
+// Schedule the scheduled publish and unpublish of the apps, they are managed by the admins of the app
+type Schedule struct {
+	schedule  logic.Schedule
+	appCenter logic.AppCenter
+}
+
+// NewSchedule new schedule
+func NewSchedule(db *gorm.DB, appCenter *AppCenter) *Schedule {
+	return &Schedule{
+		schedule:  app.NewSchedule(db),
+		appCenter: appCenter.appCenter,
+	}
+}
+
+// isAdmin reports whether the user is the admin of the app, it responds forbidden if not
+func (s *Schedule) isAdmin(c *gin.Context, appID string) bool {
+	isAdmin := s.appCenter.CheckIsAdmin(mutateContext(c), &req.CheckIsAdminReq{
+		AppID:   appID,
+		UserID:  c.GetHeader(_userID),
+		IsSuper: isSuperRole(c),
+	})
+	if !isAdmin {
+		resp.Format(nil, nil).Context(c, http.StatusForbidden)
+	}
+	return isAdmin
+}
+
+// Create schedule a change of the use status of the app
+func (s *Schedule) Create(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.CreateScheduleReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !s.isAdmin(c, rq.AppID) {
+		return
+	}
+	rq.UserID = c.GetHeader(_userID)
+	resp.Format(s.schedule.Create(ctx, rq)).Context(c)
+}
+
+// List list the schedules of the app
+func (s *Schedule) List(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.ListScheduleReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !s.isAdmin(c, rq.AppID) {
+		return
+	}
+	resp.Format(s.schedule.List(ctx, rq)).Context(c)
+}
+
+// Cancel cancel a pending schedule
+func (s *Schedule) Cancel(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.CancelScheduleReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !s.isAdmin(c, rq.AppID) {
+		return
+	}
+	rq.UserID = c.GetHeader(_userID)
+	resp.Format(s.schedule.Cancel(ctx, rq)).Context(c)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package restful
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/logic/app"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/cabin/logger"
+	"github.com/quanxiang-cloud/cabin/tailormade/resp"
+	"gorm.io/gorm"
+)
+
+// Webhook the webhooks of the apps, they are managed by the admins of the app
+type Webhook struct {
+	webhook   logic.Webhook
+	appCenter logic.AppCenter
+}
+
+// NewWebhook new webhook
+func NewWebhook(c *config.Configs, db *gorm.DB, appCenter *AppCenter) *Webhook {
+	return &Webhook{
+		webhook:   app.NewWebhook(c, db),
+		appCenter: appCenter.appCenter,
+	}
+}
+
+// isAdmin reports whether the user is the admin of the app, it responds forbidden if not
+func (w *Webhook) isAdmin(c *gin.Context, appID string) bool {
+	isAdmin := w.appCenter.CheckIsAdmin(mutateContext(c), &req.CheckIsAdminReq{
+		AppID:   appID,
+		UserID:  c.GetHeader(_userID),
+		IsSuper: isSuperRole(c),
+	})
+	if !isAdmin {
+		resp.Format(nil, nil).Context(c, http.StatusForbidden)
+	}
+	return isAdmin
+}
+
+// Create create a webhook
+func (w *Webhook) Create(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.CreateWebhookReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	rq.UserID = c.GetHeader(_userID)
+	resp.Format(w.webhook.Create(ctx, rq)).Context(c)
+}
+
+// Update update a webhook
+func (w *Webhook) Update(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.UpdateWebhookReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	rq.UserID = c.GetHeader(_userID)
+	resp.Format(w.webhook.Update(ctx, rq)).Context(c)
+}
+
+// Delete delete a webhook
+func (w *Webhook) Delete(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.DeleteWebhookReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	resp.Format(w.webhook.Delete(ctx, rq)).Context(c)
+}
+
+// List list the webhooks of an app
+func (w *Webhook) List(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.ListWebhookReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	resp.Format(w.webhook.List(ctx, rq)).Context(c)
+}
+
+// Deliveries list the deliveries to the webhooks of an app
+func (w *Webhook) Deliveries(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.ListDeliveryReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	resp.Format(w.webhook.Deliveries(ctx, rq)).Context(c)
+}
+
+// Test send a test event to a webhook
+func (w *Webhook) Test(c *gin.Context) {
+	ctx := mutateContext(c)
+	rq := &req.TestWebhookReq{}
+	if err := c.ShouldBind(rq); err != nil {
+		logger.Logger.Error(err)
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if !w.isAdmin(c, rq.AppID) {
+		return
+	}
+	resp.Format(w.webhook.Test(ctx, rq)).Context(c)
+}
@@ -14,6 +14,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/quanxiang-cloud/appcenter/pkg/redis"
 	"os"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/quanxiang-cloud/appcenter/api/restful"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	"github.com/quanxiang-cloud/cabin/logger"
 )
 
@@ -41,6 +43,11 @@ func main() {
 
 	logger.Logger = logger.New(&config.Config.Log)
 
+	shutdownTracing, err := tracing.Init(config.Config, "app-center")
+	if err != nil {
+		panic(err)
+	}
+
 	err = redis.Init()
 	if err != nil {
 		panic(err)
@@ -50,15 +57,23 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	go router.Run()
-
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		if err := router.Run(); err != nil {
+			logger.Logger.Errorf("http server is error %s", err.Error())
+			c <- syscall.SIGTERM
+		}
+	}()
+	router.Probe.SetRunning()
 	for {
 		s := <-c
 		switch s {
 		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
 			router.Close()
+			if err := shutdownTracing(context.Background()); err != nil {
+				logger.Logger.Errorf("flush spans is error %s", err.Error())
+			}
 			logger.Logger.Sync()
 			return
 		case syscall.SIGHUP:
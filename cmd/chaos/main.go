@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"os/signal"
@@ -8,7 +9,10 @@ import (
 
 	"github.com/quanxiang-cloud/appcenter/api/restful"
 	"github.com/quanxiang-cloud/appcenter/pkg/broker"
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/redis"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	"github.com/quanxiang-cloud/cabin/logger"
 )
 
@@ -28,23 +32,42 @@ func main() {
 
 	log := logger.New(&config.Log)
 
+	shutdownTracing, err := tracing.Init(config, "chaos")
+	if err != nil {
+		panic(err)
+	}
+
+	if config.Chaos.Queue.Backend == handle.QueueRedis {
+		if err := redis.InitWith(config); err != nil {
+			panic(err)
+		}
+	}
+
 	broker := broker.New()
 
 	router, err := restful.NewInitRouter(config, broker, log)
 	if err != nil {
 		panic(err)
 	}
-	go router.Run()
-
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		if err := router.Run(); err != nil {
+			log.Errorf("http server is error %s", err.Error())
+			c <- syscall.SIGTERM
+		}
+	}()
+	router.Probe.SetRunning()
 	for {
 		s := <-c
 		switch s {
 		case syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT:
 			router.Close()
-			logger.Logger.Sync()
 			broker.Cancel()
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Errorf("flush spans is error %s", err.Error())
+			}
+			logger.Logger.Sync()
 			return
 		case syscall.SIGHUP:
 		default:
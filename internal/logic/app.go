@@ -18,6 +18,7 @@ import (
 
 	"github.com/quanxiang-cloud/appcenter/internal/req"
 	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/client"
 	"github.com/quanxiang-cloud/appcenter/pkg/page"
 )
 
@@ -29,6 +30,9 @@ type AppCenter interface {
 	SuperAdminPageList(ctx context.Context, rq *req.SelectListAppCenter) (*page.Page, error)
 	// Add create a new app
 	Add(ctx context.Context, rq *req.AddAppCenter) (*resp.AdminAppCenter, error)
+	// WatchInit return the progress of the initialization of the app,
+	// it ends with an async event if the initialization is not finished in time.
+	WatchInit(ctx context.Context, appID string) <-chan client.Event
 	// Update modify app information
 	Update(ctx context.Context, rq *req.UpdateAppCenter) error
 	// UpdateStatus modify app status
@@ -79,4 +83,30 @@ type AppCenter interface {
 	ListAppByStatus(ctx context.Context, rq *req.ListAppByStatusReq) (*page.Page, error)
 
 	ChangePerPoly(ctx context.Context, rq *req.ChangePerPolyReq) (*resp.ChangePerPolyResp, error)
+
+	// StatusHistory return the transitions of the use status of the app, the latest first
+	StatusHistory(ctx context.Context, rq *req.StatusHistoryReq) (*page.Page, error)
+
+	// ------Recycle bin----------
+
+	// RecyclePageList get the deleted apps
+	RecyclePageList(ctx context.Context, rq *req.SelectRecycleList) (*page.Page, error)
+	// Restore take the app out of the recycle bin
+	Restore(ctx context.Context, rq *req.RestoreAppReq) (*resp.RestoreAppResp, error)
+	// PurgeNow purge the app right away, it is left to the purge worker if a step fails
+	PurgeNow(ctx context.Context, rq *req.PurgeAppReq) (*resp.PurgeAppResp, error)
+}
+
+// Purger hard deletes the apps in the recycle bin
+type Purger interface {
+	// Run scan the recycle bin periodically until ctx is done
+	Run(ctx context.Context)
+	// Purge tear down an app and remove it from the recycle bin
+	Purge(ctx context.Context, appID string) error
+}
+
+// Relay publishes the domain events in the outbox
+type Relay interface {
+	// Run relay the pending events periodically until ctx is done
+	Run(ctx context.Context)
 }
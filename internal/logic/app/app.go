@@ -29,9 +29,12 @@ import (
 	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
 	"github.com/quanxiang-cloud/appcenter/internal/req"
 	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 	"github.com/quanxiang-cloud/appcenter/pkg/client"
 	"github.com/quanxiang-cloud/appcenter/pkg/code"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/events"
+	"github.com/quanxiang-cloud/appcenter/pkg/metrics"
 	"github.com/quanxiang-cloud/appcenter/pkg/page"
 	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
 
@@ -39,20 +42,16 @@ import (
 )
 
 const (
-	releaseStatus     = 1
-	unReleaseStatus   = -1
-	importingStatus   = -2
-	errorImportStatus = -3
-	errorInitialize   = -4
-	unReady           = -5
-
 	appCenterRedis = "appCenter:admins:"
 	randNumber     = 5
 	preDelete      = "preDelete"
 
-	changeAdminKey   = "appCenter:admins:change"
-	changeAdminValue = "lockValue"
-	lockExpTime      = 2
+	changeAdminKey = "appCenter:admins:change"
+	lockExpTime    = 2
+	// lockWaitTime seconds a request waits for the change admin lock
+	lockWaitTime = 10
+
+	defaultInitTimeout = 60
 )
 
 // app app
@@ -61,34 +60,58 @@ type app struct {
 	app               models.AppRepo
 	appUser           models.AppUserRelationRepo
 	appScope          models.AppScopeRepo
+	appPurge          models.AppPurgeRepo
+	appTombstone      models.AppTombstoneRepo
+	outbox            models.AppOutboxRepo
+	statusHistory     models.AppStatusHistoryRepo
+	audit             models.AppAuditRepo
 	org               client.User
 	redisClient       *redis.ClusterClient
 	polyAPI           client.PolyAPI
 	flowAPI           client.Flow
 	chaosAPI          client.Chaos
+	purger            logic.Purger
 	CompatibleVersion string
 
+	flowRestoreStatus string
+
 	initServerBits int
+	initTimeout    time.Duration
 }
 
 // NewApp return a app instance
 func NewApp(c *config.Configs, db *gorm.DB) (logic.AppCenter, error) {
+	return newApp(c, db), nil
+}
+
+func newApp(c *config.Configs, db *gorm.DB) *app {
 	appcenter := &app{
 		app:               mysql.NewAppCenterRepo(),
 		appUser:           mysql.NewAppUserRelationRepo(),
 		appScope:          mysql.NewAppScopeRepo(),
+		appPurge:          mysql.NewAppPurgeRepo(),
+		appTombstone:      mysql.NewAppTombstoneRepo(),
+		outbox:            mysql.NewAppOutboxRepo(),
+		statusHistory:     mysql.NewAppStatusHistoryRepo(),
+		audit:             mysql.NewAppAuditRepo(),
 		DB:                db,
 		org:               client.NewUser(c.InternalNet),
 		polyAPI:           client.NewPolyAPI(c),
 		redisClient:       redis2.ClusterClient,
 		flowAPI:           client.NewFlow(c),
 		chaosAPI:          client.NewChaos(c),
+		purger:            NewPurger(c, db),
 		CompatibleVersion: c.CompatibleVersion,
 
+		flowRestoreStatus: c.AppCenter.Purge.FlowRestoreStatus,
+
 		initServerBits: c.InitServerBits,
+		initTimeout:    c.AppCenter.InitTimeout * time.Second,
 	}
-
-	return appcenter, nil
+	if appcenter.initTimeout <= 0 {
+		appcenter.initTimeout = defaultInitTimeout * time.Second
+	}
+	return appcenter
 }
 
 func (a *app) AdminPageList(ctx context.Context, rq *req.SelectListAppCenter) (*page.Page, error) {
@@ -148,11 +171,12 @@ func (a *app) SuperAdminPageList(ctx context.Context, rq *req.SelectListAppCente
 }
 
 func (a *app) Add(ctx context.Context, rq *req.AddAppCenter) (*resp.AdminAppCenter, error) {
-	appCenter := a.app.SelectByName(rq.AppName, a.DB)
+	db := a.DB.WithContext(ctx)
+	appCenter := a.app.SelectByName(rq.AppName, db)
 	if appCenter != nil {
 		return nil, error2.New(code.NameExist)
 	}
-	appCenter = a.app.SelectByAppSign(a.DB, rq.AppSign)
+	appCenter = a.app.SelectByAppSign(db, rq.AppSign)
 	if appCenter != nil {
 		return nil, error2.New(code.ErrIdentifiesExist)
 	}
@@ -168,12 +192,13 @@ func (a *app) Add(ctx context.Context, rq *req.AddAppCenter) (*resp.AdminAppCent
 	app.UpdateBy = rq.CreateBy
 	app.CreateTime = nowUnix
 	app.UpdateTime = nowUnix
-	app.UseStatus = unReady
+	app.UseStatus = models.StatusUnReady
 	app.Server = 0
+	app.InitBits = a.initServerBits
 	app.AppSign = rq.AppSign
 	app.Extension = getExtension(rq.Extension)
 	app.Description = rq.Description
-	tx := a.DB.Begin()
+	tx := db.Begin()
 	err := a.app.Insert(&app, tx)
 	if err != nil {
 		return nil, err
@@ -186,6 +211,26 @@ func (a *app) Add(ctx context.Context, rq *req.AddAppCenter) (*resp.AdminAppCent
 		tx.Rollback()
 		return nil, err
 	}
+	err = emit(tx, a.outbox, events.AppCreated, id, createdEvent(&app))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = a.recordStatus(tx, &transition{
+		appID:    id,
+		to:       app.UseStatus,
+		action:   actionCreate,
+		updateBy: rq.CreateBy,
+	}, 0)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppCreate, models.AuditTargetApp, id, nil, &app)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	center := resp.AdminAppCenter{
 		ID:       id,
 		CreateBy: rq.CreateBy,
@@ -202,6 +247,46 @@ func (a *app) Add(ctx context.Context, rq *req.AddAppCenter) (*resp.AdminAppCent
 	return &center, err
 }
 
+func (a *app) WatchInit(ctx context.Context, appID string) <-chan client.Event {
+	ctx, cancel := context.WithTimeout(ctx, a.initTimeout)
+	events := make(chan client.Event)
+	go func() {
+		defer cancel()
+		defer close(events)
+
+		async := client.Event{
+			Type:  define.EventAsync,
+			AppID: appID,
+			Final: true,
+		}
+		watch, err := a.chaosAPI.Watch(ctx, appID)
+		if err != nil {
+			logger.Logger.Errorf("watch the initialization of app %s is error %s", appID, err.Error())
+			async.Error = err.Error()
+		} else {
+			for e := range watch {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+				}
+				if e.Final {
+					return
+				}
+			}
+		}
+		// the client is gone, nobody is waiting for the async event
+		if ctx.Err() == context.Canceled {
+			return
+		}
+		async.Time = time.Now().Unix()
+		select {
+		case events <- async:
+		case <-time.After(time.Second):
+		}
+	}()
+	return events
+}
+
 func (a *app) Update(ctx context.Context, rq *req.UpdateAppCenter) error {
 	center := a.app.SelectByID(rq.ID, a.DB)
 	if center == nil {
@@ -233,60 +318,129 @@ func (a *app) Update(ctx context.Context, rq *req.UpdateAppCenter) error {
 	appc.Extension = getExtension(rq.Extension)
 	appc.Description = rq.Description
 	tx := a.DB.Begin()
+	before := a.app.SelectByID(rq.ID, tx)
 	err := a.app.Update(&appc, tx)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppUpdate, models.AuditTargetApp, rq.ID, before, a.app.SelectByID(rq.ID, tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 	tx.Commit()
 	return nil
 }
 
 func (a *app) UpdateStatus(ctx context.Context, rq *req.UpdateAppCenter) error {
-	appc := models.AppCenter{}
-	nowUnix := time2.NowUnix()
-	appc.ID = rq.ID
-	appc.UseStatus = rq.UseStatus
-	appc.UpdateBy = rq.UpdateBy
-	appc.UpdateTime = nowUnix
-	tx := a.DB.Begin()
-	err := a.app.Update(&appc, tx)
+	action, ok := adminActions[rq.UseStatus]
+	if !ok {
+		return error2.New(code.ErrInvalidStatus)
+	}
+	return a.transit(ctx, &transition{
+		appID:    rq.ID,
+		to:       rq.UseStatus,
+		action:   action,
+		updateBy: rq.UpdateBy,
+		audit:    ActionAppUpdateStatus,
+	})
+}
+
+func (a *app) Delete(ctx context.Context, rq *req.DelAppCenter) error {
+	tx := a.DB.WithContext(ctx).Begin()
+	// the app is locked so the admins and scopes do not change before the snapshot is saved,
+	// and an app deleted already keeps its snapshot
+	before := a.app.SelectForUpdate(tx, rq.ID)
+	if before == nil || before.DelFlag == models.Deleted {
+		tx.Rollback()
+		return error2.New(code.InvalidParams)
+	}
+	tombstone, err := a.snapshot(ctx, tx, rq.ID)
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	tx.Commit()
-	return nil
-}
+	tombstone.DeleteBy = rq.DeleteBy
 
-func (a *app) Delete(ctx context.Context, rq *req.DelAppCenter) error {
-	FiveDayTime := time.Now().AddDate(0, 0, 5)                        // Get the time five days later
-	err := a.app.UpdateDelFlag(a.DB, rq.ID, FiveDayTime.UTC().Unix()) // Mark deletion
+	FiveDayTime := time.Now().AddDate(0, 0, 5) // Get the time five days later
+	err = a.appTombstone.Save(tx, tombstone)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
-	_, err = a.flowAPI.RemoveApp(ctx, rq.ID, preDelete)
+	err = a.app.UpdateDelFlag(tx, rq.ID, FiveDayTime.UTC().Unix()) // Mark deletion
 	if err != nil {
-		logger.Logger.Error("delete flow is error ", err.Error())
+		tx.Rollback()
 		return err
 	}
-
 	// remove users under the app
-	err = a.AddAdminUser(ctx, &req.AddAdminUser{
-		AppID: rq.ID,
-	})
-
+	err = a.appUser.DeleteByAppID(rq.ID, tx)
 	if err != nil {
+		tx.Rollback()
 		logger.Logger.Error("remove admin under the app is error ", err.Error())
 		return err
 	}
-
-	err = a.appScope.DeleteByAppID(a.DB, rq.ID)
+	err = a.appScope.DeleteByAppID(tx, rq.ID)
 	if err != nil {
+		tx.Rollback()
 		logger.Logger.Error("remove users under the app is error ", err.Error())
 		return err
 	}
-	return nil
+	err = emit(tx, a.outbox, events.AppDeleted, rq.ID, map[string]interface{}{
+		"deleteBy":   rq.DeleteBy,
+		"deleteTime": FiveDayTime.UTC().Unix(),
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppDelete, models.AuditTargetApp, rq.ID, before, a.app.SelectByID(rq.ID, tx))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+
+	_, err = a.flowAPI.RemoveApp(ctx, rq.ID, preDelete)
+	if err != nil {
+		logger.Logger.Error("delete flow is error ", err.Error())
+		return err
+	}
+
+	return a.lockedAdminCacheUpdate(ctx, rq.ID, nil)
+}
+
+// snapshot record who has access to the app in tx, so it can be rebuilt on restore
+func (a *app) snapshot(ctx context.Context, tx *gorm.DB, appID string) (*models.AppTombstone, error) {
+	tombstone := &models.AppTombstone{
+		AppID:      appID,
+		Admins:     models.Strings{},
+		Scopes:     models.Scopes{},
+		CreateTime: time2.NowUnix(),
+	}
+	relations := a.appUser.SelectByAppID(appID, tx)
+	for k := range relations {
+		tombstone.Admins = append(tombstone.Admins, relations[k].UserID)
+	}
+	scopes, err := a.appScope.SelectByAppID(tx, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		tombstone.Scopes = append(tombstone.Scopes, models.Scope{
+			ScopeID: scope.ScopeID,
+			Type:    scope.Type,
+		})
+	}
+	cache, err := a.redisClient.HKeys(ctx, appCenterRedis+appID).Result()
+	if err != nil {
+		return nil, err
+	}
+	tombstone.AdminCache = cache
+	return tombstone, nil
 }
 
 func (a *app) AdminSelectByID(ctx context.Context, rq *req.SelectOneAppCenter) (*resp.AdminAppCenter, error) {
@@ -314,6 +468,12 @@ func (a *app) AdminSelectByID(ctx context.Context, rq *req.SelectOneAppCenter) (
 
 func (a *app) AddAdminUser(ctx context.Context, rq *req.AddAdminUser) error {
 	tx := a.DB.Begin()
+	// the deletion of the app waits for the change of the admins
+	if appc := a.app.SelectForUpdate(tx, rq.AppID); appc == nil || appc.DelFlag == models.Deleted {
+		tx.Rollback()
+		return error2.New(code.InvalidParams)
+	}
+	before := a.adminState(tx, rq.AppID)
 	err := a.appUser.DeleteByAppID(rq.AppID, tx)
 	if err != nil {
 		tx.Rollback()
@@ -329,61 +489,50 @@ func (a *app) AddAdminUser(ctx context.Context, rq *req.AddAdminUser) error {
 			return err
 		}
 	}
-	tx.Commit()
-	locker := redis2.NewLocker(changeAdminKey, changeAdminValue, lockExpTime, a.redisClient)
-	for {
-		out := time.After(lockExpTime * 100 * time.Millisecond)
-
-		<-out
-		lock, err := locker.Lock()
-		if err != nil {
-			return err
-		}
-		if lock {
-			err = a.redisAdminUserCacheUpdate(ctx, rq.AppID, rq.UserIDs)
-			if err != nil {
-				logger.Logger.Error("delete is error", err.Error())
-			}
-			locker.UnLock()
-			return nil
-		}
-
+	err = emit(tx, a.outbox, events.AppAdminsChanged, rq.AppID, adminsEvent(rq.UserIDs))
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppAddAdmin, models.AuditTargetApp, rq.AppID, before, a.adminState(tx, rq.AppID))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return a.lockedAdminCacheUpdate(ctx, rq.AppID, rq.UserIDs)
 }
 
 func (a *app) DelAdminUser(ctx context.Context, rq *req.DelAdminUser) error {
 	if len(rq.UserIDs) > 0 {
 		tx := a.DB.Begin()
+		if appc := a.app.SelectForUpdate(tx, rq.AppID); appc == nil || appc.DelFlag == models.Deleted {
+			tx.Rollback()
+			return error2.New(code.InvalidParams)
+		}
+		before := a.adminState(tx, rq.AppID)
 		err := a.appUser.DeleteByUserIDAndAppID(rq.AppID, rq.UserIDs, tx)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
-		tx.Commit()
-		relations := a.appUser.SelectByAppID(rq.AppID, a.DB)
+		relations := a.appUser.SelectByAppID(rq.AppID, tx)
 		userIDs := make([]string, 0)
 		for k := range relations {
 			userIDs = append(userIDs, relations[k].UserID)
 		}
-		locker := redis2.NewLocker(changeAdminKey, changeAdminValue, lockExpTime, a.redisClient)
-		for {
-			out := time.After(lockExpTime * 100 * time.Millisecond)
-
-			<-out
-			lock, err := locker.Lock()
-			if err != nil {
-				return err
-			}
-			if lock {
-				err = a.redisAdminUserCacheUpdate(ctx, rq.AppID, userIDs)
-				if err != nil {
-					logger.Logger.Error("delete redis is error ", err.Error())
-				}
-				locker.UnLock()
-				return nil
-			}
-
+		err = emit(tx, a.outbox, events.AppAdminsChanged, rq.AppID, adminsEvent(userIDs))
+		if err != nil {
+			tx.Rollback()
+			return err
 		}
+		err = recordAudit(ctx, tx, a.audit, ActionAppDelAdmin, models.AuditTargetApp, rq.AppID, before, a.adminState(tx, rq.AppID))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		tx.Commit()
+		return a.lockedAdminCacheUpdate(ctx, rq.AppID, userIDs)
 	}
 	return error2.New(code.InvalidDel)
 }
@@ -442,7 +591,7 @@ func (a *app) UserPageList(ctx context.Context, rq *req.SelectListAppCenter) (*p
 	if len(list) > 0 {
 		res := make([]resp.UserAppCenter, 0)
 		for k := range list {
-			if list[k].UseStatus == releaseStatus {
+			if list[k].UseStatus == models.StatusPublished {
 				appc := resp.UserAppCenter{}
 				appc.ID = list[k].ID
 				appc.AppName = list[k].AppName
@@ -486,7 +635,13 @@ func (a *app) GetAppsByIDs(ctx context.Context, req *req.GetAppsByIDsReq) (*resp
 // CheckIsAdmin CheckIsAdmin
 func (a *app) CheckIsAdmin(ctx context.Context, rq *req.CheckIsAdminReq) bool {
 	app := a.app.SelectByID(rq.AppID, a.DB)
-	if app == nil || app.DelFlag == models.Deleted {
+	if app == nil {
+		return false
+	}
+	if rq.Deleted {
+		return app.DelFlag == models.Deleted && (rq.IsSuper || a.isRecycleAdmin(app, rq.UserID))
+	}
+	if app.DelFlag == models.Deleted {
 		return false
 	}
 	if !rq.IsSuper {
@@ -500,6 +655,35 @@ func (a *app) CheckIsAdmin(ctx context.Context, rq *req.CheckIsAdminReq) bool {
 	return true
 }
 
+// lockedAdminCacheUpdate update the admin cache under the change admin lock
+func (a *app) lockedAdminCacheUpdate(ctx context.Context, appID string, userIDs []string) error {
+	locker := redis2.NewLocker(changeAdminKey, id2.String(randNumber), lockExpTime, a.redisClient)
+	start := time.Now()
+	timeout := time.After(lockWaitTime * time.Second)
+	for {
+		lock, err := locker.Lock()
+		if err != nil {
+			return err
+		}
+		if lock {
+			metrics.ObserveLockWait(changeAdminKey, start)
+			err = a.redisAdminUserCacheUpdate(ctx, appID, userIDs)
+			if err != nil {
+				logger.Logger.Error("update admin cache is error ", err.Error())
+			}
+			locker.UnLock()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			return error2.New(code.ErrActionTimeOut)
+		case <-time.After(lockExpTime * 100 * time.Millisecond):
+		}
+	}
+}
+
 //  redisAdminUserCacheUpdate  redisAdminUserCacheUpdate
 func (a *app) redisAdminUserCacheUpdate(ctx context.Context, appID string, userIDs []string) error {
 	usersID := a.redisClient.HKeys(ctx, appCenterRedis+appID).Val()
@@ -521,7 +705,16 @@ func (a *app) redisAdminUserCacheUpdate(ctx context.Context, appID string, userI
 // AddAppScope AddAppScope
 func (a *app) AddAppScope(ctx context.Context, req *req.AddAppScopeReq) (*resp.AddAppScopeResp, error) {
 	tx := a.DB.Begin()
-	var err error
+	// the deletion of the app waits for the change of the scopes
+	if appc := a.app.SelectForUpdate(tx, req.AppID); appc == nil || appc.DelFlag == models.Deleted {
+		tx.Rollback()
+		return nil, error2.New(code.InvalidParams)
+	}
+	before, err := a.scopeState(tx, req.AppID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	if len(req.Add) != 0 {
 		err = a.appScope.AppUserDep(tx, req.AppID, req.Add)
 		if err != nil {
@@ -536,6 +729,24 @@ func (a *app) AddAppScope(ctx context.Context, req *req.AddAppScopeReq) (*resp.A
 			return nil, err
 		}
 	}
+	err = emit(tx, a.outbox, events.AppScopeChanged, req.AppID, map[string]interface{}{
+		"add":    req.Add,
+		"delete": req.Delete,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	after, err := a.scopeState(tx, req.AppID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppScope, models.AuditTargetApp, req.AppID, before, after)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 
 	tx.Commit()
 	return &resp.AddAppScopeResp{}, nil
@@ -603,11 +814,12 @@ func (a *app) CreateImportApp(ctx context.Context, rq *req.AddAppCenter) (*resp.
 	app.AccessURL = rq.AccessURL
 	app.AppIcon = rq.AppIcon
 	app.Server = a.initServerBits
+	app.InitBits = a.initServerBits
 	app.CreateBy = rq.CreateBy
 	app.UpdateBy = rq.CreateBy
 	app.CreateTime = nowUnix
 	app.UpdateTime = nowUnix
-	app.UseStatus = importingStatus
+	app.UseStatus = models.StatusImporting
 	app.AppSign = rq.AppSign
 	tx := a.DB.Begin()
 	err := a.app.Insert(&app, tx)
@@ -622,6 +834,21 @@ func (a *app) CreateImportApp(ctx context.Context, rq *req.AddAppCenter) (*resp.
 		tx.Rollback()
 		return nil, err
 	}
+	err = emit(tx, a.outbox, events.AppCreated, id, createdEvent(&app))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = a.recordStatus(tx, &transition{
+		appID:    id,
+		to:       app.UseStatus,
+		action:   actionImport,
+		updateBy: rq.CreateBy,
+	}, 0)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	center := resp.AdminAppCenter{
 		ID:       id,
 		CreateBy: rq.CreateBy,
@@ -631,10 +858,11 @@ func (a *app) CreateImportApp(ctx context.Context, rq *req.AddAppCenter) (*resp.
 }
 
 func (a *app) FinishImport(ctx context.Context, rq *req.FinishImportReq) (*resp.FinishImportResp, error) {
-	err := a.UpdateStatus(ctx, &req.UpdateAppCenter{
-		ID:        rq.AppID,
-		UpdateBy:  rq.UpdateBy,
-		UseStatus: unReleaseStatus,
+	err := a.transit(ctx, &transition{
+		appID:    rq.AppID,
+		to:       models.StatusUnpublished,
+		action:   actionImportSucceed,
+		updateBy: rq.UpdateBy,
 	})
 	if err != nil {
 		return nil, err
@@ -643,10 +871,11 @@ func (a *app) FinishImport(ctx context.Context, rq *req.FinishImportReq) (*resp.
 }
 
 func (a *app) ErrorImport(ctx context.Context, rq *req.ErrorImportReq) (*resp.ErrorImportResp, error) {
-	err := a.UpdateStatus(ctx, &req.UpdateAppCenter{
-		ID:        rq.AppID,
-		UpdateBy:  rq.UpdateBy,
-		UseStatus: errorImportStatus,
+	err := a.transit(ctx, &transition{
+		appID:    rq.AppID,
+		to:       models.StatusImportFailed,
+		action:   actionImportFail,
+		updateBy: rq.UpdateBy,
 	})
 	if err != nil {
 		return nil, err
@@ -685,25 +914,69 @@ func getExtension(data map[string]interface{}) map[string]interface{} {
 }
 
 func (a *app) InitCallBack(ctx context.Context, rq *req.InitCallBackReq) (*resp.InitCallBackResp, error) {
-	status := &models.AppCenter{
-		ID:        rq.ID,
-		UpdateBy:  rq.UpdateBy,
-		Server:    rq.Ret,
-		UseStatus: errorInitialize,
+	appc := a.app.SelectByID(rq.ID, a.DB.WithContext(ctx))
+	if appc == nil {
+		return nil, error2.New(code.InvalidParams)
 	}
-
-	if rq.Status {
-		status.UseStatus = unReleaseStatus
+	t := &transition{
+		appID:    rq.ID,
+		to:       models.StatusInitFailed,
+		action:   actionInitFail,
+		updateBy: rq.UpdateBy,
+		server:   &rq.Ret,
+	}
+	// the app is ready when every server of its initialization is initialized,
+	// the initialized ones stay in their status
+	bits := appc.InitBits
+	if bits == 0 {
+		// the initialization was started before the servers were recorded
+		bits = a.initServerBits
+	}
+	ready := appc.UseStatus == models.StatusUnpublished || appc.UseStatus == models.StatusPublished
+	switch {
+	case rq.Status && rq.Ret&bits == bits:
+		t.action = actionInitSucceed
+		t.to = models.StatusUnpublished
+		if ready {
+			t.to = appc.UseStatus
+		}
+	case ready:
+		// a failed reinitialization of a ready app records the servers initialized,
+		// the app stays in its status and can not be published until it is initialized
+		t.to = appc.UseStatus
 	}
 
-	if err := a.app.Update(status, a.DB); err != nil {
+	if err := a.transit(ctx, t); err != nil {
 		return nil, err
 	}
 	return &resp.InitCallBackResp{}, nil
 }
 
 func (a *app) InitServer(ctx context.Context, rq *req.InitServerReq) (*resp.InitServerResp, error) {
-	err := a.chaosAPI.Init(ctx, &client.InitReq{{
+	appc := a.app.SelectByID(rq.ID, a.DB.WithContext(ctx))
+	if appc == nil {
+		return nil, error2.New(code.InvalidParams)
+	}
+	// the app is ready once the servers of this initialization are initialized
+	err := a.app.Update(&models.AppCenter{
+		ID:       rq.ID,
+		InitBits: a.initServerBits,
+	}, a.DB.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if appc.UseStatus == models.StatusInitFailed {
+		err := a.transit(ctx, &transition{
+			appID:    rq.ID,
+			to:       models.StatusUnReady,
+			action:   actionReinit,
+			updateBy: rq.CreateBy,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = a.chaosAPI.Init(ctx, &client.InitReq{{
 		AppID:    rq.ID,
 		CreateBy: rq.CreateBy,
 		Content:  a.initServerBits,
@@ -741,9 +1014,18 @@ func (a *app) ListAppByStatus(ctx context.Context, rq *req.ListAppByStatusReq) (
 }
 
 func (a *app) ChangePerPoly(ctx context.Context, rq *req.ChangePerPolyReq) (*resp.ChangePerPolyResp, error) {
-	err := a.app.ChangePerPoly(a.DB, rq.ID, rq.PerPoly) // Mark deletion
+	tx := a.DB.Begin()
+	before := a.app.SelectByID(rq.ID, tx)
+	err := a.app.ChangePerPoly(tx, rq.ID, rq.PerPoly) // Mark deletion
 	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppPerPoly, models.AuditTargetApp, rq.ID, before, a.app.SelectByID(rq.ID, tx))
+	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
+	tx.Commit()
 	return &resp.ChangePerPolyResp{}, nil
 }
@@ -34,6 +34,7 @@ type appTemplate struct {
 	db           *gorm.DB
 	templateRepo models.AppTemplateRepo
 	appRepo      models.AppRepo
+	auditRepo    models.AppAuditRepo
 }
 
 // NewAppTemplate NewAppTemplate
@@ -42,6 +43,7 @@ func NewAppTemplate(conf *config.Configs, db *gorm.DB) logic.AppTemplate {
 		db:           db,
 		templateRepo: mysql.NewAppTemplateRepo(),
 		appRepo:      mysql.NewAppCenterRepo(),
+		auditRepo:    mysql.NewAppAuditRepo(),
 	}
 }
 
@@ -89,6 +91,11 @@ func (a *appTemplate) Create(ctx context.Context, req *req.CreateTemplateReq) (*
 		tx.Rollback()
 		return nil, err
 	}
+	err = recordAudit(ctx, tx, a.auditRepo, ActionTemplateCreate, models.AuditTargetTemplate, template.ID, nil, &template)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	tx.Commit()
 	return &resp.CreateTemplateResp{
 		ID:      template.ID,
@@ -112,11 +119,17 @@ func (a *appTemplate) Delete(ctx context.Context, req *req.DeleteTemplateReq) (*
 	}
 
 	tx := a.db.Begin()
+	before := a.templateState(ctx, tx, req.ID)
 	err = a.templateRepo.Delete(ctx, tx, req.ID)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
+	err = recordAudit(ctx, tx, a.auditRepo, ActionTemplateDelete, models.AuditTargetTemplate, req.ID, before, a.templateState(ctx, tx, req.ID))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	tx.Commit()
 	return &resp.DeleteTemplateResp{}, nil
 }
@@ -190,11 +203,17 @@ func (a *appTemplate) ModifyStatus(ctx context.Context, req *req.ModifyStatusReq
 		return nil, error2.New(code.ErrNoPermission)
 	}
 	tx := a.db.Begin()
+	before := a.templateState(ctx, tx, req.ID)
 	err = a.templateRepo.ModifyStatus(ctx, tx, req.ID, req.Status)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
+	err = recordAudit(ctx, tx, a.auditRepo, ActionTemplateStatus, models.AuditTargetTemplate, req.ID, before, a.templateState(ctx, tx, req.ID))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	tx.Commit()
 	return &resp.ModifyStatusResp{}, nil
 }
@@ -223,10 +242,19 @@ func (a *appTemplate) ModifyTemplate(ctx context.Context, req *req.ModifyTemplat
 	template.UpdatedTime = time2.NowUnix()
 	template.UpdatedBy = req.UserID
 	template.UpdatedName = req.UserName
-	err = a.templateRepo.Update(ctx, a.db, template)
+	tx := a.db.Begin()
+	before := a.templateState(ctx, tx, req.ID)
+	err = a.templateRepo.Update(ctx, tx, template)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.auditRepo, ActionTemplateUpdate, models.AuditTargetTemplate, req.ID, before, a.templateState(ctx, tx, req.ID))
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
+	tx.Commit()
 	return &resp.ModifyTemplateResp{
 		ID:      template.ID,
 		Name:    template.Name,
@@ -245,11 +273,17 @@ func (a *appTemplate) FinishCreating(ctx context.Context, req *req.FinishCreatin
 	template.UpdatedBy = req.UserID
 	template.UpdatedName = req.UserName
 	tx := a.db.Begin()
+	before := a.templateState(ctx, tx, req.ID)
 	err = a.templateRepo.Update(ctx, tx, template)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
+	err = recordAudit(ctx, tx, a.auditRepo, ActionTemplateFinish, models.AuditTargetTemplate, req.ID, before, a.templateState(ctx, tx, req.ID))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	tx.Commit()
 	return &resp.FinishCreatingResp{}, nil
 }
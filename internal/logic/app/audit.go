@@ -0,0 +1,227 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	id2 "github.com/quanxiang-cloud/cabin/id"
+	"github.com/quanxiang-cloud/cabin/tailormade/header"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// actions of the audits
+const (
+	ActionAppCreate       = "app.create"
+	ActionAppUpdate       = "app.update"
+	ActionAppUpdateStatus = "app.updateStatus"
+	ActionAppDelete       = "app.delete"
+	ActionAppAddAdmin     = "app.addAdmin"
+	ActionAppDelAdmin     = "app.delAdmin"
+	ActionAppScope        = "app.scope"
+	ActionAppPerPoly      = "app.perPoly"
+	ActionAppRestore      = "app.restore"
+	ActionAppPurge        = "app.purge"
+
+	ActionTemplateCreate = "template.create"
+	ActionTemplateDelete = "template.delete"
+	ActionTemplateStatus = "template.status"
+	ActionTemplateUpdate = "template.update"
+	ActionTemplateFinish = "template.finish"
+)
+
+const exportBatch = 500
+
+var csvHeader = []string{
+	"id", "time", "tenantID", "actorID", "actorName",
+	"action", "targetType", "targetID", "before", "after",
+}
+
+type audit struct {
+	DB    *gorm.DB
+	audit models.AppAuditRepo
+}
+
+// NewAudit return the audit log
+func NewAudit(db *gorm.DB) logic.Audit {
+	return &audit{
+		DB:    db,
+		audit: mysql.NewAppAuditRepo(),
+	}
+}
+
+func (a *audit) List(ctx context.Context, rq *req.ListAuditReq) (*page.Page, error) {
+	list, total, err := a.audit.List(a.DB, auditFilter(rq), rq.Page, rq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil
+}
+
+func (a *audit) Export(ctx context.Context, rq *req.ListAuditReq, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	err := a.audit.Range(a.DB, auditFilter(rq), exportBatch, func(list []*models.AppAudit) error {
+		for _, e := range list {
+			before, _ := json.Marshal(e.Before)
+			after, _ := json.Marshal(e.After)
+			err := cw.Write([]string{
+				csvCell(e.ID),
+				time.Unix(e.CreateTime, 0).UTC().Format(time.RFC3339),
+				csvCell(e.TenantID),
+				csvCell(e.ActorID),
+				csvCell(e.ActorName),
+				csvCell(e.Action),
+				csvCell(e.TargetType),
+				csvCell(e.TargetID),
+				csvCell(string(before)),
+				csvCell(string(after)),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keep a spreadsheet from reading the cell as a formula
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func auditFilter(rq *req.ListAuditReq) *models.AuditFilter {
+	return &models.AuditFilter{
+		TargetType: rq.TargetType,
+		TargetID:   rq.TargetID,
+		ActorID:    rq.ActorID,
+		Action:     rq.Action,
+		Begin:      rq.Begin,
+		End:        rq.End,
+	}
+}
+
+// recordAudit write the audit of the mutation to tx, it is kept only if tx commits
+func recordAudit(ctx context.Context, tx *gorm.DB, repo models.AppAuditRepo, action, targetType, targetID string, before, after interface{}) error {
+	actor := logic.ActorFrom(ctx)
+	b, f := diff(before, after)
+	return repo.Insert(tx, &models.AppAudit{
+		ID:         id2.String(randNumber),
+		TenantID:   tenantID(ctx),
+		ActorID:    actor.ID,
+		ActorName:  actor.Name,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     b,
+		After:      f,
+		CreateTime: time2.NowUnix(),
+	})
+}
+
+func tenantID(ctx context.Context) string {
+	i := ctx.Value(header.TenantID)
+	tid, _ := i.(string)
+	return tid
+}
+
+// diff return the fields of the json objects of before and after which differ,
+// a nil state has no fields.
+func diff(before, after interface{}) (models.Diff, models.Diff) {
+	b, f := toDiff(before), toDiff(after)
+	for k, v := range b {
+		if w, ok := f[k]; ok && reflect.DeepEqual(v, w) {
+			delete(b, k)
+			delete(f, k)
+		}
+	}
+	return b, f
+}
+
+func toDiff(state interface{}) models.Diff {
+	ret := models.Diff{}
+	if state == nil || reflect.ValueOf(state).Kind() == reflect.Ptr && reflect.ValueOf(state).IsNil() {
+		return ret
+	}
+	body, err := json.Marshal(state)
+	if err != nil {
+		return ret
+	}
+	if err := json.Unmarshal(body, &ret); err != nil {
+		return models.Diff{}
+	}
+	return ret
+}
+
+// adminState the admins of the app seen by tx
+func (a *app) adminState(tx *gorm.DB, appID string) interface{} {
+	relations := a.appUser.SelectByAppID(appID, tx)
+	admins := make([]string, 0, len(relations))
+	for _, relation := range relations {
+		admins = append(admins, relation.UserID)
+	}
+	sort.Strings(admins)
+	return map[string]interface{}{"admins": admins}
+}
+
+// scopeState the scopes of the app seen by tx
+func (a *app) scopeState(tx *gorm.DB, appID string) (interface{}, error) {
+	scopes, err := a.appScope.SelectByAppID(tx, appID)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		ret = append(ret, scope.Type+":"+scope.ScopeID)
+	}
+	sort.Strings(ret)
+	return map[string]interface{}{"scopes": ret}, nil
+}
+
+// templateState the template seen by tx, nil if it does not exist
+func (a *appTemplate) templateState(ctx context.Context, tx *gorm.DB, id string) *models.AppTemplate {
+	template, err := a.templateRepo.SelectByID(ctx, tx, id)
+	if err != nil {
+		return nil
+	}
+	return template
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+
+	error2 "github.com/quanxiang-cloud/cabin/error"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/pkg/code"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// actions moving the apps along the lifecycle
+const (
+	actionCreate        = "create"
+	actionImport        = "import"
+	actionPublish       = "publish"
+	actionUnpublish     = "unpublish"
+	actionImportSucceed = "importSucceeded"
+	actionImportFail    = "importFailed"
+	actionInitSucceed   = "initSucceeded"
+	actionInitFail      = "initFailed"
+	actionReinit        = "reinit"
+)
+
+// transitions the statuses an app can move to from its status.
+// The apps are initialized after they are created and imported after they are imported,
+// only the initialized ones are published; the initialization fails only while the app
+// is initialized, and the failed ones are initialized again.
+var transitions = map[int][]int{
+	models.StatusUnReady:      {models.StatusUnpublished, models.StatusInitFailed},
+	models.StatusInitFailed:   {models.StatusUnpublished, models.StatusUnReady},
+	models.StatusImporting:    {models.StatusUnpublished, models.StatusImportFailed},
+	models.StatusImportFailed: {},
+	models.StatusUnpublished:  {models.StatusPublished},
+	models.StatusPublished:    {models.StatusUnpublished},
+}
+
+// adminActions the statuses the admins can move the apps to
+var adminActions = map[int]string{
+	models.StatusPublished:   actionPublish,
+	models.StatusUnpublished: actionUnpublish,
+}
+
+func canTransit(from, to int) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition a change of the use status of an app
+type transition struct {
+	appID    string
+	to       int
+	action   string
+	updateBy string
+	// server the servers initialized, it is kept if it is nil
+	server *int
+	// audit the action audited, the transition is not audited if it is empty
+	audit string
+}
+
+// transit move the app along the lifecycle, the change is recorded in the status history
+// and published in the transaction. It is a no-op if the app is already in the status.
+func (a *app) transit(ctx context.Context, t *transition) error {
+	db := a.DB.WithContext(ctx)
+	appc := a.app.SelectByID(t.appID, db)
+	if appc == nil {
+		return error2.New(code.InvalidParams)
+	}
+	if appc.UseStatus == t.to && t.server == nil {
+		return nil
+	}
+	if appc.UseStatus != t.to && !canTransit(appc.UseStatus, t.to) {
+		return error2.New(code.ErrInvalidTransition)
+	}
+
+	server := appc.Server
+	if t.server != nil {
+		server = *t.server
+	}
+	status := &models.AppCenter{
+		ID:         t.appID,
+		UseStatus:  t.to,
+		Server:     server,
+		UpdateBy:   t.updateBy,
+		UpdateTime: time2.NowUnix(),
+	}
+	// an app is published only when every server of its initialization is initialized
+	if t.to == models.StatusPublished && !initialized(appc, server) {
+		return error2.New(code.ErrAppNotReady)
+	}
+
+	tx := db.Begin()
+	var before *models.AppCenter
+	if t.audit != "" {
+		before = a.app.SelectByID(t.appID, tx)
+	}
+	ok, err := a.app.UpdateStatus(tx, status, appc.UseStatus)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !ok {
+		// the status is changed by another request in the meantime
+		tx.Rollback()
+		return error2.New(code.ErrInvalidTransition)
+	}
+	if appc.UseStatus != t.to {
+		if err = a.recordStatus(tx, t, appc.UseStatus); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if t.audit != "" {
+		err = recordAudit(ctx, tx, a.audit, t.audit, models.AuditTargetApp, t.appID, before, a.app.SelectByID(t.appID, tx))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// initialized report whether server covers the servers the initialization of the app was started with,
+// the apps initialized before those were recorded are initialized with the servers they have.
+func initialized(appc *models.AppCenter, server int) bool {
+	bits := appc.InitBits
+	if bits == 0 {
+		bits = appc.Server
+	}
+	return server&bits == bits
+}
+
+// recordStatus record the transition in the status history and publish it
+func (a *app) recordStatus(tx *gorm.DB, t *transition, from int) error {
+	err := a.statusHistory.Insert(tx, &models.AppStatusHistory{
+		AppID:      t.appID,
+		FromStatus: from,
+		ToStatus:   t.to,
+		Action:     t.action,
+		UpdateBy:   t.updateBy,
+		CreateTime: time2.NowUnix(),
+	})
+	if err != nil {
+		return err
+	}
+	// the apps becoming unpublished without being published are not unpublished by anyone
+	if event, ok := statusEvents[t.to]; ok && (t.to == models.StatusPublished || from == models.StatusPublished) {
+		return emit(tx, a.outbox, event, t.appID, map[string]interface{}{
+			"useStatus":  t.to,
+			"fromStatus": from,
+			"updateBy":   t.updateBy,
+		})
+	}
+	return nil
+}
+
+func (a *app) StatusHistory(ctx context.Context, rq *req.StatusHistoryReq) (*page.Page, error) {
+	list, total, err := a.statusHistory.List(a.DB.WithContext(ctx), rq.AppID, rq.Page, rq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"testing"
+
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+)
+
+func TestInitialized(t *testing.T) {
+	cases := []struct {
+		name string
+		app  models.AppCenter
+		// server the servers initialized reported by the callback
+		server int
+		want   bool
+	}{
+		// initialized with form and polyapi before the servers were recorded,
+		// it is publishable even if flow and structor are added to initServerBits later
+		{"legacy", models.AppCenter{Server: 3}, 3, true},
+		{"legacy more servers", models.AppCenter{Server: 3}, 15, true},
+		{"legacy missing server", models.AppCenter{Server: 3}, 1, false},
+		{"legacy missing all", models.AppCenter{Server: 3}, 0, false},
+		{"initialized", models.AppCenter{Server: 3, InitBits: 3}, 3, true},
+		{"more servers", models.AppCenter{Server: 3, InitBits: 11}, 15, true},
+		{"missing server", models.AppCenter{Server: 15, InitBits: 11}, 3, false},
+		{"missing all", models.AppCenter{Server: 15, InitBits: 15}, 0, false},
+	}
+	for _, c := range cases {
+		if got := initialized(&c.app, c.server); got != c.want {
+			t.Errorf("%s: initialized = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCanTransit(t *testing.T) {
+	cases := []struct {
+		from, to int
+		want     bool
+	}{
+		{models.StatusUnpublished, models.StatusPublished, true},
+		{models.StatusPublished, models.StatusUnpublished, true},
+		{models.StatusUnReady, models.StatusPublished, false},
+		{models.StatusInitFailed, models.StatusPublished, false},
+		{models.StatusImporting, models.StatusPublished, false},
+		{models.StatusImportFailed, models.StatusUnpublished, false},
+		{models.StatusUnReady, models.StatusInitFailed, true},
+		{models.StatusInitFailed, models.StatusUnReady, true},
+		{models.StatusUnpublished, models.StatusInitFailed, false},
+		{models.StatusPublished, models.StatusInitFailed, false},
+	}
+	for _, c := range cases {
+		if got := canTransit(c.from, c.to); got != c.want {
+			t.Errorf("canTransit(%d, %d) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
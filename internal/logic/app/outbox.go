@@ -0,0 +1,203 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	id2 "github.com/quanxiang-cloud/cabin/id"
+	"github.com/quanxiang-cloud/cabin/logger"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/events"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+const (
+	relayKey = "appCenter:relay"
+	// relayLockExp seconds a replica holds the relay of a batch
+	relayLockExp = 30
+
+	defaultRelayInterval  = 1000
+	defaultRelayBatch     = 100
+	defaultRelayMaxRetry  = 20
+	defaultRelayRetention = 168
+)
+
+// statusEvents events of the changes of the use status
+var statusEvents = map[int]string{
+	models.StatusPublished:   events.AppPublished,
+	models.StatusUnpublished: events.AppUnpublished,
+}
+
+// emit write the event to the outbox in tx, it is published once tx commits
+func emit(tx *gorm.DB, outbox models.AppOutboxRepo, eventType, appID string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return outbox.Insert(tx, &models.AppOutbox{
+		EventID:    id2.String(randNumber),
+		EventType:  eventType,
+		AppID:      appID,
+		Data:       body,
+		Status:     models.OutboxPending,
+		CreateTime: time2.NowUnix(),
+	})
+}
+
+type relay struct {
+	DB          *gorm.DB
+	outbox      models.AppOutboxRepo
+	publisher   events.Publisher
+	redisClient *redis.ClusterClient
+
+	interval  time.Duration
+	batch     int
+	maxRetry  int
+	retention time.Duration
+}
+
+// NewRelay return the relay of the outbox to the transports of c
+func NewRelay(c *config.Configs, db *gorm.DB) logic.Relay {
+	conf := c.AppCenter.Events
+	r := &relay{
+		DB:          db,
+		outbox:      mysql.NewAppOutboxRepo(),
+		publisher:   events.Fanout(events.New(c, redis2.ClusterClient), newSubscriptions(db)),
+		redisClient: redis2.ClusterClient,
+		interval:    conf.Interval * time.Millisecond,
+		batch:       conf.Batch,
+		maxRetry:    conf.MaxRetry,
+		retention:   conf.Retention * time.Hour,
+	}
+	if r.interval <= 0 {
+		r.interval = defaultRelayInterval * time.Millisecond
+	}
+	if r.batch <= 0 {
+		r.batch = defaultRelayBatch
+	}
+	if r.maxRetry <= 0 {
+		r.maxRetry = defaultRelayMaxRetry
+	}
+	if r.retention <= 0 {
+		r.retention = defaultRelayRetention * time.Hour
+	}
+	return r
+}
+
+func (r *relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relayPending(ctx)
+		}
+	}
+}
+
+// relayPending publish the pending events in the order they were written,
+// only one replica of app-center does it at the same time.
+// The replica stops once it loses the lock, so an event is not published by two of them at once.
+func (r *relay) relayPending(ctx context.Context) {
+	locker := redis2.NewLocker(relayKey, id2.String(randNumber), relayLockExp, r.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		logger.Logger.Error("relay lock is error ", err.Error())
+		return
+	}
+	if !lock {
+		return
+	}
+	defer locker.UnLock()
+
+	list, err := r.outbox.ListPending(r.DB, r.batch)
+	if err != nil {
+		logger.Logger.Error("list pending events is error ", err.Error())
+		return
+	}
+	for _, e := range list {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := locker.Extend(); err != nil {
+			logger.Logger.Error("extend relay lock is error ", err.Error())
+			return
+		}
+		// the later events wait for a failed one to keep the order
+		if !r.publish(ctx, e) {
+			break
+		}
+	}
+
+	before := time.Now().Add(-r.retention).Unix()
+	if err := r.outbox.DeleteRelayed(r.DB, before); err != nil {
+		logger.Logger.Error("delete relayed events is error ", err.Error())
+	}
+}
+
+// publish publish the event and record the result, it reports whether the event is done with.
+func (r *relay) publish(ctx context.Context, e *models.AppOutbox) bool {
+	err := r.publisher.Publish(ctx, &events.Event{
+		ID:    e.EventID,
+		Type:  e.EventType,
+		AppID: e.AppID,
+		Data:  e.Data,
+		Time:  e.CreateTime,
+	})
+	if err == nil {
+		e.Status = models.OutboxRelayed
+		e.LastError = ""
+	} else {
+		e.Retry++
+		e.LastError = err.Error()
+		logger.Logger.Errorf("relay event %s %s is error %s", e.EventType, e.EventID, err.Error())
+		if e.Retry >= r.maxRetry {
+			e.Status = models.OutboxGivenUp
+			logger.Logger.Errorf("event %s %s is given up after %d retries", e.EventType, e.EventID, e.Retry)
+		}
+	}
+	e.RelayTime = time2.NowUnix()
+	if err := r.outbox.Save(r.DB, e); err != nil {
+		logger.Logger.Error("save event is error ", err.Error())
+		return false
+	}
+	return e.Status != models.OutboxPending
+}
+
+func createdEvent(app *models.AppCenter) interface{} {
+	return map[string]interface{}{
+		"appName":   app.AppName,
+		"appSign":   app.AppSign,
+		"createBy":  app.CreateBy,
+		"useStatus": app.UseStatus,
+	}
+}
+
+func adminsEvent(userIDs []string) interface{} {
+	return map[string]interface{}{
+		"admins": userIDs,
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	error2 "github.com/quanxiang-cloud/cabin/error"
+	id2 "github.com/quanxiang-cloud/cabin/id"
+	"github.com/quanxiang-cloud/cabin/logger"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
+	"github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/appcenter/pkg/code"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+const (
+	deleteStatus = "delete"
+
+	purgeKey = "appCenter:purge"
+	// purgeAppKey the lock of one app, purge and restore of the app exclude each other
+	purgeAppKey = "appCenter:purge:app:"
+	// purgeLockExp seconds a replica holds the purge of an app,
+	// the lock is extended before each app so a slow scan keeps it.
+	purgeLockExp         = 60
+	defaultPurgeInterval = 10
+)
+
+// bits of the teardown steps, they are recorded in AppPurge.Steps
+const (
+	purgeStructorTable = 1 << iota
+	purgeStructorPer
+	purgePolyAPI
+	purgeFlow
+	purgeScope
+	purgeAdmin
+	purgeAdminCache
+	purgeWebhook
+	purgeStatusHistory
+	purgeSchedule
+)
+
+type purgeStep struct {
+	bit  int
+	name string
+	do   func(ctx context.Context, appID string) error
+}
+
+type purger struct {
+	DB          *gorm.DB
+	app         models.AppRepo
+	appUser     models.AppUserRelationRepo
+	appScope    models.AppScopeRepo
+	appPurge    models.AppPurgeRepo
+	webhook     models.AppWebhookRepo
+	delivery    models.AppWebhookDeliveryRepo
+	history     models.AppStatusHistoryRepo
+	schedule    models.AppScheduleRepo
+	structor    client.Structor
+	polyAPI     client.PolyAPI
+	flowAPI     client.Flow
+	redisClient *redis.ClusterClient
+
+	interval time.Duration
+	steps    []purgeStep
+}
+
+// NewPurger return a recycle bin purger
+func NewPurger(c *config.Configs, db *gorm.DB) logic.Purger {
+	interval := c.AppCenter.Purge.Interval
+	if interval <= 0 {
+		interval = defaultPurgeInterval
+	}
+	p := &purger{
+		DB:          db,
+		app:         mysql.NewAppCenterRepo(),
+		appUser:     mysql.NewAppUserRelationRepo(),
+		appScope:    mysql.NewAppScopeRepo(),
+		appPurge:    mysql.NewAppPurgeRepo(),
+		webhook:     mysql.NewAppWebhookRepo(),
+		delivery:    mysql.NewAppWebhookDeliveryRepo(),
+		history:     mysql.NewAppStatusHistoryRepo(),
+		schedule:    mysql.NewAppScheduleRepo(),
+		structor:    client.NewStructor(c),
+		polyAPI:     client.NewPolyAPI(c),
+		flowAPI:     client.NewFlow(c),
+		redisClient: redis2.ClusterClient,
+		interval:    interval * time.Minute,
+	}
+	p.steps = []purgeStep{
+		{purgeStructorTable, "structor table", p.removeTable},
+		{purgeStructorPer, "structor permission", p.removePer},
+		{purgePolyAPI, "polyapi", p.removePoly},
+		{purgeFlow, "flow", p.removeFlow},
+		{purgeScope, "scope", p.removeScope},
+		{purgeAdmin, "admin", p.removeAdmin},
+		{purgeAdminCache, "admin cache", p.removeAdminCache},
+		{purgeWebhook, "webhook", p.removeWebhook},
+		{purgeStatusHistory, "status history", p.removeStatusHistory},
+		{purgeSchedule, "schedule", p.removeSchedule},
+	}
+	return p
+}
+
+func (p *purger) Run(ctx context.Context) {
+	// the apps expired while no replica was running are purged right away
+	p.purgeExpired(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.purgeExpired(ctx)
+		}
+	}
+}
+
+// purgeExpired purge the apps whose delete time is expired,
+// only one replica of app-center does it at the same time and it stops once it loses the lock.
+func (p *purger) purgeExpired(ctx context.Context) {
+	locker := redis2.NewLocker(purgeKey, id2.String(randNumber), purgeLockExp, p.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		logger.Logger.Error("purge lock is error ", err.Error())
+		return
+	}
+	if !lock {
+		return
+	}
+	defer locker.UnLock()
+
+	apps, err := p.app.GetDeleteList(p.DB, time.Now().UTC().Unix())
+	if err != nil {
+		logger.Logger.Error("get delete list is error ", err.Error())
+		return
+	}
+	for _, app := range apps {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := locker.Extend(); err != nil {
+			logger.Logger.Error("extend purge lock is error ", err.Error())
+			return
+		}
+		if err := p.Purge(ctx, app.ID); err != nil {
+			logger.Logger.Errorf("purge app %s is error %s", app.ID, err.Error())
+		}
+	}
+}
+
+// newAppPurgeLocker return the lock of the purge of an app
+func newAppPurgeLocker(appID string, conn redis.UniversalClient) *redis2.Locker {
+	return redis2.NewLocker(purgeAppKey+appID, id2.String(randNumber), purgeLockExp, conn)
+}
+
+func (p *purger) Purge(ctx context.Context, appID string) error {
+	locker := newAppPurgeLocker(appID, p.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		return err
+	}
+	if !lock {
+		return error2.New(code.ErrAppPurging)
+	}
+	defer locker.UnLock()
+
+	app := p.app.SelectByID(appID, p.DB)
+	if app == nil || app.DelFlag != models.Deleted {
+		return nil
+	}
+
+	nowUnix := time2.NowUnix()
+	progress := p.appPurge.SelectByAppID(p.DB, appID)
+	if progress == nil {
+		progress = &models.AppPurge{
+			AppID:      appID,
+			CreateTime: nowUnix,
+		}
+	}
+
+	for _, step := range p.steps {
+		if progress.Steps&step.bit != 0 {
+			continue
+		}
+		if err := locker.Extend(); err != nil {
+			return err
+		}
+		if err := step.do(ctx, appID); err != nil {
+			progress.Retry++
+			progress.LastError = fmt.Sprintf("%s: %s", step.name, err.Error())
+			progress.UpdateTime = time2.NowUnix()
+			if err := p.appPurge.Save(p.DB, progress); err != nil {
+				logger.Logger.Error("save purge progress is error ", err.Error())
+			}
+			return err
+		}
+		progress.Steps |= step.bit
+		progress.LastError = ""
+		progress.UpdateTime = time2.NowUnix()
+		if err := p.appPurge.Save(p.DB, progress); err != nil {
+			return err
+		}
+	}
+
+	// the tombstone is kept as the record of who had access to the app
+	tx := p.DB.Begin()
+	if err := p.app.Delete(appID, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := p.appPurge.Delete(tx, appID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (p *purger) removeTable(ctx context.Context, appID string) error {
+	resp, err := p.structor.RemoveTable(ctx, appID)
+	return client.CheckDelResp(resp, err)
+}
+
+func (p *purger) removePer(ctx context.Context, appID string) error {
+	resp, err := p.structor.RemovePer(ctx, appID)
+	return client.CheckDelResp(resp, err)
+}
+
+func (p *purger) removePoly(ctx context.Context, appID string) error {
+	resp, err := p.polyAPI.DeleteAPP(ctx, appID)
+	return client.CheckDelResp(resp, err)
+}
+
+func (p *purger) removeFlow(ctx context.Context, appID string) error {
+	resp, err := p.flowAPI.RemoveApp(ctx, appID, deleteStatus)
+	return client.CheckDelResp(resp, err)
+}
+
+func (p *purger) removeScope(ctx context.Context, appID string) error {
+	return p.appScope.DeleteByAppID(p.DB, appID)
+}
+
+func (p *purger) removeAdmin(ctx context.Context, appID string) error {
+	return p.appUser.DeleteByAppID(appID, p.DB)
+}
+
+func (p *purger) removeAdminCache(ctx context.Context, appID string) error {
+	return p.redisClient.Del(ctx, appCenterRedis+appID).Err()
+}
+
+func (p *purger) removeWebhook(ctx context.Context, appID string) error {
+	if err := p.delivery.DeleteByAppID(p.DB, appID); err != nil {
+		return err
+	}
+	return p.webhook.DeleteByAppID(p.DB, appID)
+}
+
+func (p *purger) removeStatusHistory(ctx context.Context, appID string) error {
+	return p.history.DeleteByAppID(p.DB, appID)
+}
+
+func (p *purger) removeSchedule(ctx context.Context, appID string) error {
+	return p.schedule.DeleteByAppID(p.DB, appID)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"time"
+
+	error2 "github.com/quanxiang-cloud/cabin/error"
+	"github.com/quanxiang-cloud/cabin/logger"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/code"
+	"github.com/quanxiang-cloud/appcenter/pkg/events"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// isRecycleAdmin the creator and the admins recorded in the tombstone
+// can manage the app in the recycle bin.
+func (a *app) isRecycleAdmin(app *models.AppCenter, userID string) bool {
+	if userID == "" {
+		return false
+	}
+	if app.CreateBy == userID {
+		return true
+	}
+	tombstone := a.appTombstone.SelectByAppID(a.DB, app.ID)
+	if tombstone == nil {
+		return false
+	}
+	for _, admin := range tombstone.Admins {
+		if admin == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *app) RecyclePageList(ctx context.Context, rq *req.SelectRecycleList) (*page.Page, error) {
+	list, total := a.app.SelectDeletedByPage(rq.UserID, rq.AppName, rq.Page, rq.Limit, !rq.IsSuper, a.DB)
+	if len(list) > 0 {
+		res := make([]resp.RecycleAppCenter, 0, len(list))
+		for k := range list {
+			res = append(res, resp.RecycleAppCenter{
+				ID:         list[k].ID,
+				AppName:    list[k].AppName,
+				AppIcon:    list[k].AppIcon,
+				CreateBy:   list[k].CreateBy,
+				UpdateBy:   list[k].UpdateBy,
+				UpdateTime: list[k].UpdateTime,
+				DeleteTime: list[k].DeleteTime,
+			})
+		}
+		page := page.Page{}
+		page.Data = res
+		page.TotalCount = total
+		return &page, nil
+	}
+	return nil, nil
+}
+
+func (a *app) Restore(ctx context.Context, rq *req.RestoreAppReq) (*resp.RestoreAppResp, error) {
+	appc := a.app.SelectByID(rq.ID, a.DB)
+	if appc == nil || appc.DelFlag != models.Deleted {
+		return nil, error2.New(code.InvalidParams)
+	}
+	// the purge worker is kept away from the app until it is restored
+	locker := newAppPurgeLocker(rq.ID, a.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		return nil, err
+	}
+	if !lock {
+		return nil, error2.New(code.ErrAppPurging)
+	}
+	defer locker.UnLock()
+	if progress := a.appPurge.SelectByAppID(a.DB, rq.ID); progress != nil && progress.Steps != 0 {
+		return nil, error2.New(code.ErrAppPurging)
+	}
+	// name and sign may be taken by another app after the deletion
+	if a.app.SelectByName(appc.AppName, a.DB) != nil {
+		return nil, error2.New(code.NameExist)
+	}
+	if appc.AppSign != "" && a.app.SelectByAppSign(a.DB, appc.AppSign) != nil {
+		return nil, error2.New(code.ErrIdentifiesExist)
+	}
+
+	tx := a.DB.Begin()
+	before := a.app.SelectByID(rq.ID, tx)
+	err = a.app.Restore(tx, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = a.app.Update(&models.AppCenter{
+		ID:         rq.ID,
+		UpdateBy:   rq.UpdateBy,
+		UpdateTime: time2.NowUnix(),
+	}, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = a.appUser.DeleteByAppID(rq.ID, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	admins, scopes, cache := a.fromTombstone(appc)
+	for _, userID := range admins {
+		err = a.appUser.Add(&models.AppUseRelation{
+			AppID:  rq.ID,
+			UserID: userID,
+		}, tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	err = a.appScope.DeleteByAppID(tx, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(scopes) != 0 {
+		err = a.appScope.AppUserDep(tx, rq.ID, scopes)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	err = a.appTombstone.Delete(tx, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = a.appPurge.Delete(tx, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = emit(tx, a.outbox, events.AppRestored, rq.ID, map[string]interface{}{
+		"updateBy": rq.UpdateBy,
+		"admins":   admins,
+		"scopes":   scopes,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppRestore, models.AuditTargetApp, rq.ID, before, a.app.SelectByID(rq.ID, tx))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	// flow is restored last, so a failure of it rolls back the app
+	if a.flowRestoreStatus != "" {
+		_, err = a.flowAPI.RemoveApp(ctx, rq.ID, a.flowRestoreStatus)
+		if err != nil {
+			tx.Rollback()
+			logger.Logger.Error("restore flow is error ", err.Error())
+			return nil, err
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		if a.flowRestoreStatus != "" {
+			if _, err := a.flowAPI.RemoveApp(ctx, rq.ID, preDelete); err != nil {
+				logger.Logger.Error("delete flow again is error ", err.Error())
+			}
+		}
+		return nil, err
+	}
+
+	err = a.lockedAdminCacheUpdate(ctx, rq.ID, cache)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.RestoreAppResp{}, nil
+}
+
+// fromTombstone return the admins, scopes and admin cache recorded when the app was deleted,
+// the creator is the admin of the apps deleted without a tombstone.
+func (a *app) fromTombstone(appc *models.AppCenter) (admins []string, scopes []models.Scope, cache []string) {
+	tombstone := a.appTombstone.SelectByAppID(a.DB, appc.ID)
+	if tombstone == nil || len(tombstone.Admins) == 0 {
+		return []string{appc.CreateBy}, nil, []string{appc.CreateBy}
+	}
+	cache = tombstone.AdminCache
+	if len(cache) == 0 {
+		cache = tombstone.Admins
+	}
+	return tombstone.Admins, tombstone.Scopes, cache
+}
+
+func (a *app) PurgeNow(ctx context.Context, rq *req.PurgeAppReq) (*resp.PurgeAppResp, error) {
+	appc := a.app.SelectByID(rq.ID, a.DB)
+	if appc == nil || appc.DelFlag != models.Deleted {
+		return nil, error2.New(code.InvalidParams)
+	}
+	// the purge worker takes the app even if the purge below fails
+	tx := a.DB.Begin()
+	err := a.app.UpdateDelFlag(tx, rq.ID, time.Now().UTC().Unix())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, a.audit, ActionAppPurge, models.AuditTargetApp, rq.ID, appc, a.app.SelectByID(rq.ID, tx))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	err = a.purger.Purge(ctx, rq.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.PurgeAppResp{}, nil
+}
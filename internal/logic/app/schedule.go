@@ -0,0 +1,317 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"time"
+
+	error2 "github.com/quanxiang-cloud/cabin/error"
+	id2 "github.com/quanxiang-cloud/cabin/id"
+	"github.com/quanxiang-cloud/cabin/logger"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/code"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+// actions of the schedules
+const (
+	ActionAppSchedule       = "app.schedule"
+	ActionAppCancelSchedule = "app.cancelSchedule"
+
+	actionScheduledPublish   = "scheduledPublish"
+	actionScheduledUnpublish = "scheduledUnpublish"
+)
+
+const (
+	scheduleKey = "appCenter:schedule"
+	// scheduleLockExp seconds a replica holds the execution of a schedule,
+	// the running schedules are taken over by the others after it.
+	scheduleLockExp = 30
+	// scheduleSaveRetry times the outcome of a schedule is saved,
+	// a schedule left running is taken over and checked again by the replicas
+	scheduleSaveRetry = 3
+
+	defaultScheduleInterval = 10
+	defaultScheduleBatch    = 50
+)
+
+// scheduledActions the actions of the status history of the executed schedules
+var scheduledActions = map[int]string{
+	models.StatusPublished:   actionScheduledPublish,
+	models.StatusUnpublished: actionScheduledUnpublish,
+}
+
+type schedule struct {
+	DB       *gorm.DB
+	app      models.AppRepo
+	schedule models.AppScheduleRepo
+	audit    models.AppAuditRepo
+}
+
+// NewSchedule return the schedules of the apps
+func NewSchedule(db *gorm.DB) logic.Schedule {
+	return &schedule{
+		DB:       db,
+		app:      mysql.NewAppCenterRepo(),
+		schedule: mysql.NewAppScheduleRepo(),
+		audit:    mysql.NewAppAuditRepo(),
+	}
+}
+
+func (s *schedule) Create(ctx context.Context, rq *req.CreateScheduleReq) (*resp.CreateScheduleResp, error) {
+	if _, ok := scheduledActions[rq.UseStatus]; !ok {
+		return nil, error2.New(code.ErrInvalidStatus)
+	}
+	if rq.ExecuteTime <= time.Now().Unix() {
+		return nil, error2.New(code.InvalidTimestamp)
+	}
+	appc := s.app.SelectByID(rq.AppID, s.DB)
+	if appc == nil || appc.DelFlag == models.Deleted {
+		return nil, error2.New(code.InvalidParams)
+	}
+
+	nowUnix := time2.NowUnix()
+	sc := &models.AppSchedule{
+		ID:          id2.String(randNumber),
+		AppID:       rq.AppID,
+		UseStatus:   rq.UseStatus,
+		ExecuteTime: rq.ExecuteTime,
+		Status:      models.SchedulePending,
+		CreateBy:    rq.UserID,
+		CreateTime:  nowUnix,
+		UpdateBy:    rq.UserID,
+		UpdateTime:  nowUnix,
+	}
+	tx := s.DB.Begin()
+	if err := s.schedule.Insert(tx, sc); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, s.audit, ActionAppSchedule, models.AuditTargetApp, rq.AppID, nil, sc); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &resp.CreateScheduleResp{
+		ID: sc.ID,
+	}, nil
+}
+
+func (s *schedule) List(ctx context.Context, rq *req.ListScheduleReq) (*page.Page, error) {
+	status := -1
+	if rq.Status != nil {
+		status = *rq.Status
+	}
+	list, total, err := s.schedule.List(s.DB, rq.AppID, status, rq.Page, rq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil
+}
+
+func (s *schedule) Cancel(ctx context.Context, rq *req.CancelScheduleReq) (*resp.CancelScheduleResp, error) {
+	sc := s.schedule.SelectByID(s.DB, rq.AppID, rq.ID)
+	if sc == nil {
+		return nil, error2.New(code.ErrDataNotExist)
+	}
+	before := *sc
+	sc.Status = models.ScheduleCanceled
+	sc.UpdateBy = rq.UserID
+	sc.UpdateTime = time2.NowUnix()
+	tx := s.DB.Begin()
+	ok, err := s.schedule.UpdateStatus(tx, sc, models.SchedulePending)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if !ok {
+		// the schedule is executed or canceled already
+		tx.Rollback()
+		return nil, error2.New(code.ErrInvalidTransition)
+	}
+	err = recordAudit(ctx, tx, s.audit, ActionAppCancelSchedule, models.AuditTargetApp, rq.AppID, &before, sc)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &resp.CancelScheduleResp{}, nil
+}
+
+type scheduler struct {
+	*app
+	schedule models.AppScheduleRepo
+
+	interval time.Duration
+	batch    int
+}
+
+// NewScheduler return the worker executing the due schedules
+func NewScheduler(c *config.Configs, db *gorm.DB) logic.Scheduler {
+	conf := c.AppCenter.Schedule
+	s := &scheduler{
+		app:      newApp(c, db),
+		schedule: mysql.NewAppScheduleRepo(),
+		interval: conf.Interval * time.Second,
+		batch:    conf.Batch,
+	}
+	if s.interval <= 0 {
+		s.interval = defaultScheduleInterval * time.Second
+	}
+	if s.batch <= 0 {
+		s.batch = defaultScheduleBatch
+	}
+	return s
+}
+
+func (s *scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.executeDue(ctx)
+		}
+	}
+}
+
+// executeDue execute the due schedules, only one replica of app-center does it at the same time.
+// The replica stops once it loses the lock, so a schedule is not executed by two of them at once.
+func (s *scheduler) executeDue(ctx context.Context) {
+	locker := redis2.NewLocker(scheduleKey, id2.String(randNumber), scheduleLockExp, s.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		logger.Logger.Error("schedule lock is error ", err.Error())
+		return
+	}
+	if !lock {
+		return
+	}
+	defer locker.UnLock()
+
+	now := time.Now()
+	stale := now.Add(-scheduleLockExp * time.Second).Unix()
+	list, err := s.schedule.ListDue(s.DB, now.Unix(), stale, s.batch)
+	if err != nil {
+		logger.Logger.Error("list due schedules is error ", err.Error())
+		return
+	}
+	for _, sc := range list {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := locker.Extend(); err != nil {
+			logger.Logger.Error("extend schedule lock is error ", err.Error())
+			return
+		}
+		s.execute(ctx, sc)
+	}
+}
+
+// execute move the app to the status of the schedule, the schedule is claimed first,
+// a running one left by a lost replica is executed again unless the app has changed since.
+func (s *scheduler) execute(ctx context.Context, sc *models.AppSchedule) {
+	from := sc.Status
+	claimedAt := sc.UpdateTime
+	sc.Status = models.ScheduleRunning
+	sc.UpdateTime = time2.NowUnix()
+	ok, err := s.schedule.UpdateStatus(s.DB, sc, from)
+	if err != nil {
+		logger.Logger.Error("claim schedule is error ", err.Error())
+		return
+	}
+	if !ok {
+		// canceled in the meantime
+		return
+	}
+
+	if from == models.ScheduleRunning {
+		err = s.executeStale(ctx, sc, claimedAt)
+	} else {
+		err = s.executeSchedule(ctx, sc)
+	}
+	sc.Status = models.ScheduleExecuted
+	sc.LastError = ""
+	if err != nil {
+		sc.Status = models.ScheduleFailed
+		sc.LastError = err.Error()
+		logger.Logger.Errorf("schedule %s of app %s is error %s", sc.ID, sc.AppID, err.Error())
+	}
+	sc.UpdateTime = time2.NowUnix()
+	s.save(sc)
+}
+
+// save save the outcome of the running schedule, it is retried
+// so the schedule is not left running and executed again.
+func (s *scheduler) save(sc *models.AppSchedule) {
+	for i := 0; i < scheduleSaveRetry; i++ {
+		_, err := s.schedule.UpdateStatus(s.DB, sc, models.ScheduleRunning)
+		if err == nil {
+			return
+		}
+		logger.Logger.Errorf("save schedule %s is error %s", sc.ID, err.Error())
+		time.Sleep(time.Second)
+	}
+}
+
+// executeStale execute the schedule left running by a lost replica since claimedAt,
+// it is executed already if the app has transitioned by the schedule since then,
+// and it is not executed again if the app has transitioned otherwise.
+func (s *scheduler) executeStale(ctx context.Context, sc *models.AppSchedule, claimedAt int64) error {
+	list, err := s.statusHistory.ListSince(s.DB, sc.AppID, claimedAt)
+	if err != nil {
+		return err
+	}
+	for _, history := range list {
+		if history.Action == scheduledActions[sc.UseStatus] && history.ToStatus == sc.UseStatus {
+			return nil
+		}
+	}
+	if len(list) > 0 {
+		return error2.New(code.ErrInvalidTransition)
+	}
+	return s.executeSchedule(ctx, sc)
+}
+
+func (s *scheduler) executeSchedule(ctx context.Context, sc *models.AppSchedule) error {
+	appc := s.app.app.SelectByID(sc.AppID, s.DB)
+	if appc == nil || appc.DelFlag == models.Deleted {
+		return error2.New(code.InvalidParams)
+	}
+	return s.transit(ctx, &transition{
+		appID:    sc.AppID,
+		to:       sc.UseStatus,
+		action:   scheduledActions[sc.UseStatus],
+		updateBy: sc.CreateBy,
+	})
+}
@@ -0,0 +1,470 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	error2 "github.com/quanxiang-cloud/cabin/error"
+	id2 "github.com/quanxiang-cloud/cabin/id"
+	"github.com/quanxiang-cloud/cabin/logger"
+	time2 "github.com/quanxiang-cloud/cabin/time"
+	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/internal/logic"
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"github.com/quanxiang-cloud/appcenter/internal/models/mysql"
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/code"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/events"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+// actions of the webhooks
+const (
+	ActionWebhookCreate = "webhook.create"
+	ActionWebhookUpdate = "webhook.update"
+	ActionWebhookDelete = "webhook.delete"
+)
+
+const (
+	webhookTestEvent = "webhook.test"
+	secretBytes      = 32
+
+	dispatchKey = "appCenter:webhook"
+	// dispatchLockExp seconds a replica holds the delivery of a batch
+	dispatchLockExp = 60
+
+	defaultDispatchInterval = 5
+	defaultDispatchBatch    = 50
+	defaultMaxAttempts      = 8
+	defaultBackoff          = 10
+	defaultMaxBackoff       = 3600
+	defaultDeliveryTimeout  = 5
+)
+
+type webhook struct {
+	DB       *gorm.DB
+	hook     models.AppWebhookRepo
+	delivery models.AppWebhookDeliveryRepo
+	audit    models.AppAuditRepo
+	guard    *urlGuard
+	client   http.Client
+}
+
+// NewWebhook return the webhooks of the apps
+func NewWebhook(c *config.Configs, db *gorm.DB) logic.Webhook {
+	guard := newURLGuard(c)
+	return &webhook{
+		DB:       db,
+		hook:     mysql.NewAppWebhookRepo(),
+		delivery: mysql.NewAppWebhookDeliveryRepo(),
+		audit:    mysql.NewAppAuditRepo(),
+		guard:    guard,
+		client:   deliveryClient(c, guard),
+	}
+}
+
+// deliveryClient return the client of the deliveries, it is outside the internal network
+func deliveryClient(c *config.Configs, guard *urlGuard) http.Client {
+	timeout := c.AppCenter.Webhook.Timeout
+	if timeout <= 0 {
+		timeout = defaultDeliveryTimeout
+	}
+	return guard.client(timeout * time.Second)
+}
+
+// checkURL return an error if the webhook can not post to u
+func (w *webhook) checkURL(ctx context.Context, u string) error {
+	if err := w.guard.check(ctx, u); err != nil {
+		logger.Logger.Errorf("url %s of webhook is denied: %s", u, err.Error())
+		return error2.New(code.ErrWebhookURL)
+	}
+	return nil
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (w *webhook) Create(ctx context.Context, rq *req.CreateWebhookReq) (*resp.CreateWebhookResp, error) {
+	if err := w.checkURL(ctx, rq.URL); err != nil {
+		return nil, err
+	}
+	secret := rq.Secret
+	if secret == "" {
+		var err error
+		if secret, err = newSecret(); err != nil {
+			return nil, err
+		}
+	}
+	nowUnix := time2.NowUnix()
+	hook := &models.AppWebhook{
+		ID:         id2.String(randNumber),
+		AppID:      rq.AppID,
+		URL:        rq.URL,
+		Secret:     secret,
+		Events:     rq.Events,
+		Enabled:    true,
+		CreateBy:   rq.UserID,
+		CreateTime: nowUnix,
+		UpdateBy:   rq.UserID,
+		UpdateTime: nowUnix,
+	}
+	tx := w.DB.Begin()
+	if err := w.hook.Insert(tx, hook); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, w.audit, ActionWebhookCreate, models.AuditTargetApp, rq.AppID, nil, hook); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &resp.CreateWebhookResp{
+		ID:     hook.ID,
+		Secret: secret,
+	}, nil
+}
+
+func (w *webhook) Update(ctx context.Context, rq *req.UpdateWebhookReq) (*resp.UpdateWebhookResp, error) {
+	if err := w.checkURL(ctx, rq.URL); err != nil {
+		return nil, err
+	}
+	tx := w.DB.Begin()
+	hook := w.hook.SelectByID(tx, rq.AppID, rq.ID)
+	if hook == nil {
+		tx.Rollback()
+		return nil, error2.New(code.ErrDataNotExist)
+	}
+	before := *hook
+	hook.URL = rq.URL
+	hook.Events = rq.Events
+	hook.Enabled = rq.Enabled
+	hook.UpdateBy = rq.UserID
+	hook.UpdateTime = time2.NowUnix()
+	if err := w.hook.Update(tx, hook); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordAudit(ctx, tx, w.audit, ActionWebhookUpdate, models.AuditTargetApp, rq.AppID, &before, hook); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &resp.UpdateWebhookResp{}, nil
+}
+
+func (w *webhook) Delete(ctx context.Context, rq *req.DeleteWebhookReq) (*resp.DeleteWebhookResp, error) {
+	tx := w.DB.Begin()
+	before := w.hook.SelectByID(tx, rq.AppID, rq.ID)
+	if before == nil {
+		tx.Rollback()
+		return nil, error2.New(code.ErrDataNotExist)
+	}
+	err := w.hook.Delete(tx, rq.AppID, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = w.delivery.DeleteByWebhookID(tx, rq.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = recordAudit(ctx, tx, w.audit, ActionWebhookDelete, models.AuditTargetApp, rq.AppID, before, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &resp.DeleteWebhookResp{}, nil
+}
+
+func (w *webhook) List(ctx context.Context, rq *req.ListWebhookReq) (*page.Page, error) {
+	list, total, err := w.hook.List(w.DB, rq.AppID, rq.Page, rq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil
+}
+
+func (w *webhook) Deliveries(ctx context.Context, rq *req.ListDeliveryReq) (*page.Page, error) {
+	list, total, err := w.delivery.List(w.DB, rq.AppID, rq.WebhookID, rq.Page, rq.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil
+}
+
+func (w *webhook) Test(ctx context.Context, rq *req.TestWebhookReq) (*resp.TestWebhookResp, error) {
+	hook := w.hook.SelectByID(w.DB, rq.AppID, rq.ID)
+	if hook == nil {
+		return nil, error2.New(code.ErrDataNotExist)
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"webhookID": hook.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := newDelivery(hook, &events.Event{
+		ID:    id2.String(randNumber),
+		Type:  webhookTestEvent,
+		AppID: hook.AppID,
+		Data:  data,
+		Time:  time2.NowUnix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	attempt(ctx, &w.client, hook, delivery)
+	if delivery.Status == models.DeliveryPending {
+		delivery.Status = models.DeliveryFailed
+	}
+	if err := w.delivery.Insert(w.DB, delivery); err != nil {
+		return nil, err
+	}
+	return &resp.TestWebhookResp{
+		DeliveryID:   delivery.ID,
+		Delivered:    delivery.Status == models.DeliveryDelivered,
+		ResponseCode: delivery.ResponseCode,
+		Error:        delivery.LastError,
+	}, nil
+}
+
+func newDelivery(hook *models.AppWebhook, e *events.Event) (*models.AppWebhookDelivery, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	nowUnix := time2.NowUnix()
+	return &models.AppWebhookDelivery{
+		ID:         id2.String(randNumber),
+		WebhookID:  hook.ID,
+		AppID:      hook.AppID,
+		EventID:    e.ID,
+		EventType:  e.Type,
+		Payload:    payload,
+		Status:     models.DeliveryPending,
+		NextTime:   nowUnix,
+		CreateTime: nowUnix,
+		UpdateTime: nowUnix,
+	}, nil
+}
+
+// attempt post the payload of the delivery to the webhook and record the result in it
+func attempt(ctx context.Context, c *http.Client, hook *models.AppWebhook, delivery *models.AppWebhookDelivery) {
+	e := &events.Event{
+		ID:   delivery.EventID,
+		Type: delivery.EventType,
+	}
+	status, err := events.Post(ctx, c, hook.URL, hook.Secret, e, delivery.Payload)
+	delivery.Attempts++
+	delivery.ResponseCode = status
+	delivery.UpdateTime = time2.NowUnix()
+	if err != nil {
+		delivery.LastError = err.Error()
+		return
+	}
+	delivery.Status = models.DeliveryDelivered
+	delivery.LastError = ""
+}
+
+// subscriptions publish the events to the deliveries of the webhooks subscribing them
+type subscriptions struct {
+	DB       *gorm.DB
+	hook     models.AppWebhookRepo
+	delivery models.AppWebhookDeliveryRepo
+}
+
+func newSubscriptions(db *gorm.DB) events.Publisher {
+	return &subscriptions{
+		DB:       db,
+		hook:     mysql.NewAppWebhookRepo(),
+		delivery: mysql.NewAppWebhookDeliveryRepo(),
+	}
+}
+
+func (s *subscriptions) Publish(ctx context.Context, e *events.Event) error {
+	hooks, err := s.hook.SelectByAppID(s.DB, e.AppID)
+	if err != nil {
+		return err
+	}
+	deliveries := make([]*models.AppWebhookDelivery, 0, len(hooks))
+	for _, hook := range hooks {
+		if !hook.Subscribe(e.Type) {
+			continue
+		}
+		delivery, err := newDelivery(hook, e)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return s.delivery.Insert(s.DB, deliveries...)
+}
+
+type dispatcher struct {
+	DB          *gorm.DB
+	hook        models.AppWebhookRepo
+	delivery    models.AppWebhookDeliveryRepo
+	client      http.Client
+	redisClient *redis.ClusterClient
+
+	interval    time.Duration
+	batch       int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewDispatcher return the dispatcher of the deliveries to the webhooks
+func NewDispatcher(c *config.Configs, db *gorm.DB) logic.Dispatcher {
+	conf := c.AppCenter.Webhook
+	d := &dispatcher{
+		DB:          db,
+		hook:        mysql.NewAppWebhookRepo(),
+		delivery:    mysql.NewAppWebhookDeliveryRepo(),
+		client:      deliveryClient(c, newURLGuard(c)),
+		redisClient: redis2.ClusterClient,
+		interval:    conf.Interval * time.Second,
+		batch:       conf.Batch,
+		maxAttempts: conf.MaxAttempts,
+		backoff:     conf.Backoff * time.Second,
+		maxBackoff:  conf.MaxBackoff * time.Second,
+	}
+	if d.interval <= 0 {
+		d.interval = defaultDispatchInterval * time.Second
+	}
+	if d.batch <= 0 {
+		d.batch = defaultDispatchBatch
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.backoff <= 0 {
+		d.backoff = defaultBackoff * time.Second
+	}
+	if d.maxBackoff <= 0 {
+		d.maxBackoff = defaultMaxBackoff * time.Second
+	}
+	return d
+}
+
+func (d *dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatchDue(ctx)
+		}
+	}
+}
+
+// dispatchDue attempt the due deliveries, only one replica of app-center does it at the same time.
+// The replica stops once it loses the lock, so a delivery is not attempted by two of them at once.
+func (d *dispatcher) dispatchDue(ctx context.Context) {
+	locker := redis2.NewLocker(dispatchKey, id2.String(randNumber), dispatchLockExp, d.redisClient)
+	lock, err := locker.Lock()
+	if err != nil {
+		logger.Logger.Error("webhook lock is error ", err.Error())
+		return
+	}
+	if !lock {
+		return
+	}
+	defer locker.UnLock()
+
+	list, err := d.delivery.ListDue(d.DB, time2.NowUnix(), d.batch)
+	if err != nil {
+		logger.Logger.Error("list due deliveries is error ", err.Error())
+		return
+	}
+	for _, delivery := range list {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := locker.Extend(); err != nil {
+			logger.Logger.Error("extend webhook lock is error ", err.Error())
+			return
+		}
+		d.dispatch(ctx, delivery)
+	}
+}
+
+func (d *dispatcher) dispatch(ctx context.Context, delivery *models.AppWebhookDelivery) {
+	hook := d.hook.SelectByID(d.DB, delivery.AppID, delivery.WebhookID)
+	switch {
+	case hook == nil || !hook.Enabled:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = "webhook is removed or disabled"
+		delivery.UpdateTime = time2.NowUnix()
+	default:
+		attempt(ctx, &d.client, hook, delivery)
+		if delivery.Status != models.DeliveryPending {
+			break
+		}
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = models.DeliveryFailed
+			logger.Logger.Errorf("delivery %s of event %s to webhook %s failed after %d attempts",
+				delivery.ID, delivery.EventID, delivery.WebhookID, delivery.Attempts)
+			break
+		}
+		delivery.NextTime = time.Now().Add(d.nextBackoff(delivery.Attempts)).Unix()
+	}
+	if err := d.delivery.Save(d.DB, delivery); err != nil {
+		logger.Logger.Error("save delivery is error ", err.Error())
+	}
+}
+
+// nextBackoff return the wait before the retry following the attempts
+func (d *dispatcher) nextBackoff(attempts int) time.Duration {
+	backoff := d.backoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= d.maxBackoff {
+			return d.maxBackoff
+		}
+	}
+	return backoff
+}
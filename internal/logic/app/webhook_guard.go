@@ -0,0 +1,139 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/quanxiang-cloud/cabin/logger"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+const (
+	dialTimeout         = 5 * time.Second
+	tlsHandshakeTimeout = 5 * time.Second
+)
+
+// deniedNets the loopback, private, link-local and reserved networks,
+// the services of the cluster and the metadata of the cloud are in them.
+var deniedNets = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+var errDeniedAddress = errors.New("address is denied")
+
+// urlGuard keep the webhooks of the apps away from the internal network
+type urlGuard struct {
+	denied   []*net.IPNet
+	resolver *net.Resolver
+}
+
+func newURLGuard(c *config.Configs) *urlGuard {
+	g := &urlGuard{
+		resolver: net.DefaultResolver,
+	}
+	for _, cidr := range append(append([]string{}, deniedNets...), c.AppCenter.Webhook.DeniedNets...) {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			logger.Logger.Errorf("denied net %s of webhook is error %s", cidr, err.Error())
+			continue
+		}
+		g.denied = append(g.denied, n)
+	}
+	return g
+}
+
+func (g *urlGuard) allowed(ip net.IP) bool {
+	for _, n := range g.denied {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// check return an error if u is not an http url of a host outside the denied networks
+func (g *urlGuard) check(ctx context.Context, u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("scheme %s is not http", parsed.Scheme)
+	}
+	host := parsed.Hostname()
+	if host == "" {
+		return fmt.Errorf("host is required")
+	}
+	addrs, err := g.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.allowed(addr.IP) {
+			return fmt.Errorf("%w: %s of %s", errDeniedAddress, addr.IP, host)
+		}
+	}
+	return nil
+}
+
+// client return the client of the deliveries, the address is checked again on dial
+// so a host resolving to another address after the check is denied as well.
+func (g *urlGuard) client(timeout time.Duration) http.Client {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !g.allowed(ip) {
+				return fmt.Errorf("%w: %s", errDeniedAddress, host)
+			}
+			return nil
+		},
+	}
+	return http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: tlsHandshakeTimeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
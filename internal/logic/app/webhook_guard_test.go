@@ -0,0 +1,49 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+func TestURLGuardCheck(t *testing.T) {
+	c := &config.Configs{}
+	c.AppCenter.Webhook.DeniedNets = []string{"203.0.113.0/24"}
+	guard := newURLGuard(c)
+	cases := []struct {
+		url  string
+		want bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://93.184.216.34:8080/hook", true},
+		{"ftp://93.184.216.34/hook", false},
+		{"file:///etc/passwd", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://172.20.0.1/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::1]/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://203.0.113.7/hook", false},
+	}
+	for _, c := range cases {
+		if got := guard.check(context.Background(), c.url) == nil; got != c.want {
+			t.Errorf("check(%s) = %v, want %v", c.url, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+	"io"
+
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// Audit the audit log of the mutations of app-center
+type Audit interface {
+	// List return the audits matching the request, the latest first
+	List(ctx context.Context, rq *req.ListAuditReq) (*page.Page, error)
+	// Export write the audits matching the request to w as csv, the latest first
+	Export(ctx context.Context, rq *req.ListAuditReq, w io.Writer) error
+}
+
+// Actor the user making the request
+type Actor struct {
+	ID   string
+	Name string
+}
+
+type actorKey struct{}
+
+// WithActor return ctx carrying the actor, the mutations are audited as done by it
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom return the actor of ctx
+func ActorFrom(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	return actor
+}
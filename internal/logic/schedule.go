@@ -0,0 +1,37 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// Schedule the scheduled publish and unpublish of the apps
+type Schedule interface {
+	Create(ctx context.Context, rq *req.CreateScheduleReq) (*resp.CreateScheduleResp, error)
+	// List return the schedules of the app, the latest due first
+	List(ctx context.Context, rq *req.ListScheduleReq) (*page.Page, error)
+	// Cancel cancel the schedule if it is not executed yet
+	Cancel(ctx context.Context, rq *req.CancelScheduleReq) (*resp.CancelScheduleResp, error)
+}
+
+// Scheduler executes the due schedules
+type Scheduler interface {
+	// Run execute the due schedules periodically until ctx is done
+	Run(ctx context.Context)
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+
+	"github.com/quanxiang-cloud/appcenter/internal/req"
+	"github.com/quanxiang-cloud/appcenter/internal/resp"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// Webhook the outbound webhooks of the apps
+type Webhook interface {
+	Create(ctx context.Context, rq *req.CreateWebhookReq) (*resp.CreateWebhookResp, error)
+	Update(ctx context.Context, rq *req.UpdateWebhookReq) (*resp.UpdateWebhookResp, error)
+	// Delete delete the webhook and its deliveries
+	Delete(ctx context.Context, rq *req.DeleteWebhookReq) (*resp.DeleteWebhookResp, error)
+	List(ctx context.Context, rq *req.ListWebhookReq) (*page.Page, error)
+	// Deliveries return the delivery history, the latest first
+	Deliveries(ctx context.Context, rq *req.ListDeliveryReq) (*page.Page, error)
+	// Test deliver a test event to the webhook right now, it is not retried
+	Test(ctx context.Context, rq *req.TestWebhookReq) (*resp.TestWebhookResp, error)
+}
+
+// Dispatcher delivers the events to the webhooks of the apps
+type Dispatcher interface {
+	// Run attempt the due deliveries periodically until ctx is done
+	Run(ctx context.Context)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// type of the audit target
+const (
+	AuditTargetApp      = "app"
+	AuditTargetTemplate = "template"
+)
+
+// Diff the fields changed by an action by name
+type Diff map[string]interface{}
+
+// Value Value
+func (d Diff) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return json.Marshal(d)
+}
+
+// Scan Scan
+func (d *Diff) Scan(data interface{}) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data.([]byte), &d)
+}
+
+// AppAudit a mutation of app-center, it is never updated or deleted
+type AppAudit struct {
+	ID         string `gorm:"column:id;type:varchar(64);primary_key" json:"id"`
+	TenantID   string `gorm:"column:tenant_id;type:varchar(64);" json:"tenantID"`
+	ActorID    string `gorm:"column:actor_id;type:varchar(64);" json:"actorID"`
+	ActorName  string `gorm:"column:actor_name;type:varchar(80);" json:"actorName"`
+	Action     string `gorm:"column:action;type:varchar(64);" json:"action"`
+	TargetType string `gorm:"column:target_type;type:varchar(32);" json:"targetType"`
+	TargetID   string `gorm:"column:target_id;type:varchar(64);" json:"targetID"`
+	Before     Diff   `gorm:"column:before_value;" json:"before"`
+	After      Diff   `gorm:"column:after_value;" json:"after"`
+	CreateTime int64  `gorm:"column:create_time;type:bigint;" json:"createTime"`
+}
+
+// TableName TableName
+func (AppAudit) TableName() string {
+	return "t_app_audit"
+}
+
+// AuditFilter the conditions of the audits, the empty ones match all
+type AuditFilter struct {
+	TargetType string
+	TargetID   string
+	ActorID    string
+	Action     string
+	// Begin, End range of the create time, inclusive
+	Begin int64
+	End   int64
+}
+
+// AppAuditRepo AppAuditRepo
+type AppAuditRepo interface {
+	Insert(db *gorm.DB, audit *AppAudit) error
+	// List return the audits matching the filter, the latest first
+	List(db *gorm.DB, filter *AuditFilter, page, limit int) ([]*AppAudit, int64, error)
+	// Range call fn with the audits matching the filter in batches, the latest first
+	Range(db *gorm.DB, filter *AuditFilter, batch int, fn func([]*AppAudit) error) error
+}
@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 
 	"gorm.io/gorm"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 )
 
 const (
@@ -27,6 +29,22 @@ const (
 	NotDeleted int64 = 0
 )
 
+// lifecycle status of the apps, see UseStatus
+const (
+	// StatusPublished the app is visible to its users
+	StatusPublished = 1
+	// StatusUnpublished the app is ready but not visible to its users
+	StatusUnpublished = -1
+	// StatusImporting the app is being imported
+	StatusImporting = -2
+	// StatusImportFailed the import of the app failed
+	StatusImportFailed = -3
+	// StatusInitFailed some servers of the app failed to initialize
+	StatusInitFailed = -4
+	// StatusUnReady the servers of the app are being initialized
+	StatusUnReady = define.StatusUnReady
+)
+
 // Extension Extension
 type Extension map[string]interface{}
 
@@ -42,7 +60,8 @@ type AppCenter struct {
 	UpdateTime int64  `gorm:"column:update_time;type:bigint; " json:"updateTime"`
 	UseStatus  int    `gorm:"column:use_status;"  json:"useStatus"` //published1，unpublished-1
 	Server     int    `gorm:"column:server;" json:"server"`
-	DelFlag    int64  `gorm:"column:del_flag;"  json:"delFlag"` //delete marker 0 not deleted 1 deleted
+	InitBits   int    `gorm:"column:init_bits;" json:"initBits"` //servers of the last initialization, ready once Server covers them
+	DelFlag    int64  `gorm:"column:del_flag;"  json:"delFlag"`  //delete marker 0 not deleted 1 deleted
 
 	// The default time is five days after you click delete.
 	// If you click delete in the recycle bin, the delete time changes to the current time
@@ -72,6 +91,8 @@ func (AppCenter) TableName() string {
 type AppRepo interface {
 	SelectByPage(userID, name string, status, page, limit int, isAdmin bool, db *gorm.DB) ([]AppCenter, int64)
 	SelectByID(ID string, db *gorm.DB) *AppCenter
+	// SelectForUpdate select the app and lock it until the transaction db commits
+	SelectForUpdate(db *gorm.DB, id string) *AppCenter
 	SelectByName(Name string, db *gorm.DB) *AppCenter
 	Insert(app *AppCenter, tx *gorm.DB) error
 	Update(app *AppCenter, tx *gorm.DB) error
@@ -82,4 +103,10 @@ type AppRepo interface {
 	GetDeleteList(db *gorm.DB, deleteTime int64) ([]*AppCenter, error)
 	SelectByAppSign(db *gorm.DB, appSign string) *AppCenter
 	SelectByStatus(db *gorm.DB, status int, page, limit int) (list []AppCenter, total int64)
+	// SelectDeletedByPage the apps in the recycle bin, only the ones created or administered by userID if onlyAdminOf
+	SelectDeletedByPage(userID, name string, page, limit int, onlyAdminOf bool, db *gorm.DB) ([]AppCenter, int64)
+	Restore(db *gorm.DB, id string) error
+	// UpdateStatus update the use status, the servers and the updater of the app if its use status is still from,
+	// the zero values are updated as well. It reports whether the app is updated
+	UpdateStatus(db *gorm.DB, app *AppCenter, from int) (bool, error)
 }
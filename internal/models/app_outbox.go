@@ -0,0 +1,57 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// status of the events in the outbox
+const (
+	OutboxPending = iota
+	OutboxRelayed
+	OutboxGivenUp
+)
+
+// AppOutbox a domain event of an app waiting to be relayed,
+// it is written in the transaction of the change.
+type AppOutbox struct {
+	ID         int64           `gorm:"column:id;primary_key;autoIncrement" json:"id"`
+	EventID    string          `gorm:"column:event_id;type:varchar(64);" json:"eventID"`
+	EventType  string          `gorm:"column:event_type;type:varchar(64);" json:"eventType"`
+	AppID      string          `gorm:"column:app_id;type:varchar(64);" json:"appID"`
+	Data       json.RawMessage `gorm:"column:data;type:json;" json:"data"`
+	Status     int             `gorm:"column:status;" json:"status"`
+	Retry      int             `gorm:"column:retry;" json:"retry"`
+	LastError  string          `gorm:"column:last_error;type:text;" json:"lastError"`
+	CreateTime int64           `gorm:"column:create_time;type:bigint;" json:"createTime"`
+	RelayTime  int64           `gorm:"column:relay_time;type:bigint;" json:"relayTime"`
+}
+
+// TableName TableName
+func (AppOutbox) TableName() string {
+	return "t_app_outbox"
+}
+
+// AppOutboxRepo AppOutboxRepo
+type AppOutboxRepo interface {
+	Insert(db *gorm.DB, event *AppOutbox) error
+	// ListPending return the pending events in the order they were written
+	ListPending(db *gorm.DB, limit int) ([]*AppOutbox, error)
+	Save(db *gorm.DB, event *AppOutbox) error
+	// DeleteRelayed delete the events relayed before the unix time
+	DeleteRelayed(db *gorm.DB, before int64) error
+}
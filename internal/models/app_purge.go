@@ -0,0 +1,38 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// AppPurge progress of hard deleting an app from the recycle bin
+type AppPurge struct {
+	AppID      string `gorm:"column:app_id;type:varchar(64);primary_key" json:"appID"`
+	Steps      int    `gorm:"column:steps;" json:"steps"` // bits of the finished teardown steps
+	Retry      int    `gorm:"column:retry;" json:"retry"`
+	LastError  string `gorm:"column:last_error;type:text;" json:"lastError"`
+	CreateTime int64  `gorm:"column:create_time;type:bigint;" json:"createTime"`
+	UpdateTime int64  `gorm:"column:update_time;type:bigint;" json:"updateTime"`
+}
+
+// TableName TableName
+func (AppPurge) TableName() string {
+	return "t_app_purge"
+}
+
+// AppPurgeRepo AppPurgeRepo
+type AppPurgeRepo interface {
+	SelectByAppID(db *gorm.DB, appID string) *AppPurge
+	Save(db *gorm.DB, purge *AppPurge) error
+	Delete(db *gorm.DB, appID string) error
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// status of the schedules
+const (
+	SchedulePending = iota
+	ScheduleRunning
+	ScheduleExecuted
+	ScheduleFailed
+	ScheduleCanceled
+)
+
+// AppSchedule a scheduled change of the use status of an app
+type AppSchedule struct {
+	ID          string `gorm:"column:id;type:varchar(64);primary_key" json:"id"`
+	AppID       string `gorm:"column:app_id;type:varchar(64);" json:"appID"`
+	UseStatus   int    `gorm:"column:use_status;" json:"useStatus"`
+	ExecuteTime int64  `gorm:"column:execute_time;type:bigint;" json:"executeTime"`
+	Status      int    `gorm:"column:status;" json:"status"`
+	LastError   string `gorm:"column:last_error;type:text;" json:"lastError"`
+	CreateBy    string `gorm:"column:create_by;type:varchar(64);" json:"createBy"`
+	CreateTime  int64  `gorm:"column:create_time;type:bigint;" json:"createTime"`
+	UpdateBy    string `gorm:"column:update_by;type:varchar(64);" json:"updateBy"`
+	UpdateTime  int64  `gorm:"column:update_time;type:bigint;" json:"updateTime"`
+}
+
+// TableName TableName
+func (AppSchedule) TableName() string {
+	return "t_app_schedule"
+}
+
+// AppScheduleRepo AppScheduleRepo
+type AppScheduleRepo interface {
+	Insert(db *gorm.DB, schedule *AppSchedule) error
+	SelectByID(db *gorm.DB, appID, id string) *AppSchedule
+	// List return the schedules of the app, the latest due first, status below zero is any status
+	List(db *gorm.DB, appID string, status, page, limit int) ([]*AppSchedule, int64, error)
+	// ListDue return the pending schedules due at now and the running ones updated before stale
+	ListDue(db *gorm.DB, now, stale int64, limit int) ([]*AppSchedule, error)
+	// UpdateStatus update the schedule if its status is still from, it reports whether the schedule is updated
+	UpdateStatus(db *gorm.DB, schedule *AppSchedule, from int) (bool, error)
+	DeleteByAppID(db *gorm.DB, appID string) error
+}
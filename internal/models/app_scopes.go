@@ -41,4 +41,5 @@ type AppScopeRepo interface {
 	GetAppByUserID(db *gorm.DB, appID string, userID, depID string) (int64, error)
 	GetByAppID(db *gorm.DB, appID string, page, size int) ([]*AppScope, int64, error)
 	DeleteByAppID(db *gorm.DB, appID string) error
+	SelectByAppID(db *gorm.DB, appID string) ([]*AppScope, error)
 }
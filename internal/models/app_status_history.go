@@ -0,0 +1,42 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import "gorm.io/gorm"
+
+// AppStatusHistory a transition of the use status of an app
+type AppStatusHistory struct {
+	ID         int64  `gorm:"column:id;primary_key;autoIncrement" json:"id"`
+	AppID      string `gorm:"column:app_id;type:varchar(64);" json:"appID"`
+	FromStatus int    `gorm:"column:from_status;" json:"fromStatus"` // 0 when the app is created
+	ToStatus   int    `gorm:"column:to_status;" json:"toStatus"`
+	Action     string `gorm:"column:action;type:varchar(64);" json:"action"`
+	UpdateBy   string `gorm:"column:update_by;type:varchar(64);" json:"updateBy"`
+	CreateTime int64  `gorm:"column:create_time;type:bigint;" json:"createTime"`
+}
+
+// TableName TableName
+func (AppStatusHistory) TableName() string {
+	return "t_app_status_history"
+}
+
+// AppStatusHistoryRepo AppStatusHistoryRepo
+type AppStatusHistoryRepo interface {
+	Insert(db *gorm.DB, history *AppStatusHistory) error
+	// List return the transitions of the app, the latest first
+	List(db *gorm.DB, appID string, page, limit int) ([]*AppStatusHistory, int64, error)
+	// ListSince return the transitions of the app since the unix time, the earliest first
+	ListSince(db *gorm.DB, appID string, since int64) ([]*AppStatusHistory, error)
+	DeleteByAppID(db *gorm.DB, appID string) error
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// Strings Strings
+type Strings []string
+
+// Value Value
+func (s Strings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan Scan
+func (s *Strings) Scan(data interface{}) error {
+	return json.Unmarshal(data.([]byte), &s)
+}
+
+// Scopes Scopes
+type Scopes []Scope
+
+// Value Value
+func (s Scopes) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan Scan
+func (s *Scopes) Scan(data interface{}) error {
+	return json.Unmarshal(data.([]byte), &s)
+}
+
+// AppTombstone the access configuration of an app at the time it is deleted
+type AppTombstone struct {
+	AppID      string  `gorm:"column:app_id;type:varchar(64);primary_key" json:"appID"`
+	Admins     Strings `gorm:"column:admins;" json:"admins"`          // t_app_user_relation
+	Scopes     Scopes  `gorm:"column:scopes;" json:"scopes"`          // t_app_scope
+	AdminCache Strings `gorm:"column:admin_cache;" json:"adminCache"` // redis admin hash
+	DeleteBy   string  `gorm:"column:delete_by;type:varchar(64);" json:"deleteBy"`
+	CreateTime int64   `gorm:"column:create_time;type:bigint;" json:"createTime"`
+}
+
+// TableName TableName
+func (AppTombstone) TableName() string {
+	return "t_app_tombstone"
+}
+
+// AppTombstoneRepo AppTombstoneRepo
+type AppTombstoneRepo interface {
+	SelectByAppID(db *gorm.DB, appID string) *AppTombstone
+	Save(db *gorm.DB, tombstone *AppTombstone) error
+	Delete(db *gorm.DB, appID string) error
+}
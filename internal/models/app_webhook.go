@@ -0,0 +1,104 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// status of the deliveries
+const (
+	DeliveryPending = iota
+	DeliveryDelivered
+	DeliveryFailed
+)
+
+// AppWebhook an outbound webhook of an app
+type AppWebhook struct {
+	ID         string  `gorm:"column:id;type:varchar(64);primary_key" json:"id"`
+	AppID      string  `gorm:"column:app_id;type:varchar(64);" json:"appID"`
+	URL        string  `gorm:"column:url;type:varchar(512);" json:"url"`
+	Secret     string  `gorm:"column:secret;type:varchar(128);" json:"-"`
+	Events     Strings `gorm:"column:events;" json:"events"`
+	Enabled    bool    `gorm:"column:enabled;" json:"enabled"`
+	CreateBy   string  `gorm:"column:create_by;type:varchar(64);" json:"createBy"`
+	CreateTime int64   `gorm:"column:create_time;type:bigint;" json:"createTime"`
+	UpdateBy   string  `gorm:"column:update_by;type:varchar(64);" json:"updateBy"`
+	UpdateTime int64   `gorm:"column:update_time;type:bigint;" json:"updateTime"`
+}
+
+// TableName TableName
+func (AppWebhook) TableName() string {
+	return "t_app_webhook"
+}
+
+// Subscribe reports whether the webhook receives the events of the type
+func (w *AppWebhook) Subscribe(eventType string) bool {
+	if !w.Enabled {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// AppWebhookDelivery a delivery of an event to a webhook
+type AppWebhookDelivery struct {
+	ID           string          `gorm:"column:id;type:varchar(64);primary_key" json:"id"`
+	WebhookID    string          `gorm:"column:webhook_id;type:varchar(64);" json:"webhookID"`
+	AppID        string          `gorm:"column:app_id;type:varchar(64);" json:"appID"`
+	EventID      string          `gorm:"column:event_id;type:varchar(64);" json:"eventID"`
+	EventType    string          `gorm:"column:event_type;type:varchar(64);" json:"eventType"`
+	Payload      json.RawMessage `gorm:"column:payload;type:json;" json:"payload"`
+	Status       int             `gorm:"column:status;" json:"status"`
+	Attempts     int             `gorm:"column:attempts;" json:"attempts"`
+	ResponseCode int             `gorm:"column:response_code;" json:"responseCode"`
+	LastError    string          `gorm:"column:last_error;type:text;" json:"lastError"`
+	NextTime     int64           `gorm:"column:next_time;type:bigint;" json:"nextTime"`
+	CreateTime   int64           `gorm:"column:create_time;type:bigint;" json:"createTime"`
+	UpdateTime   int64           `gorm:"column:update_time;type:bigint;" json:"updateTime"`
+}
+
+// TableName TableName
+func (AppWebhookDelivery) TableName() string {
+	return "t_app_webhook_delivery"
+}
+
+// AppWebhookRepo AppWebhookRepo
+type AppWebhookRepo interface {
+	Insert(db *gorm.DB, hook *AppWebhook) error
+	Update(db *gorm.DB, hook *AppWebhook) error
+	Delete(db *gorm.DB, appID, id string) error
+	SelectByID(db *gorm.DB, appID, id string) *AppWebhook
+	SelectByAppID(db *gorm.DB, appID string) ([]*AppWebhook, error)
+	List(db *gorm.DB, appID string, page, limit int) ([]*AppWebhook, int64, error)
+	DeleteByAppID(db *gorm.DB, appID string) error
+}
+
+// AppWebhookDeliveryRepo AppWebhookDeliveryRepo
+type AppWebhookDeliveryRepo interface {
+	// Insert insert the deliveries, the ones of an event already delivered to the webhook are ignored
+	Insert(db *gorm.DB, deliveries ...*AppWebhookDelivery) error
+	Save(db *gorm.DB, delivery *AppWebhookDelivery) error
+	// ListDue return the pending deliveries whose next attempt is due at the unix time
+	ListDue(db *gorm.DB, now int64, limit int) ([]*AppWebhookDelivery, error)
+	List(db *gorm.DB, appID, webhookID string, page, limit int) ([]*AppWebhookDelivery, int64, error)
+	DeleteByWebhookID(db *gorm.DB, webhookID string) error
+	DeleteByAppID(db *gorm.DB, appID string) error
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+	"gorm.io/gorm"
+)
+
+type appAuditRepo struct {
+}
+
+func (a *appAuditRepo) Insert(db *gorm.DB, audit *models.AppAudit) error {
+	return db.Create(audit).Error
+}
+
+func (a *appAuditRepo) List(db *gorm.DB, filter *models.AuditFilter, page, limit int) ([]*models.AppAudit, int64, error) {
+	db = a.where(db.Model(&models.AppAudit{}), filter).Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	newPage := page2.NewPage(page, limit, total)
+
+	list := make([]*models.AppAudit, 0)
+	err := db.Order("create_time desc, id desc").
+		Limit(newPage.PageSize).
+		Offset(newPage.StartIndex).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (a *appAuditRepo) Range(db *gorm.DB, filter *models.AuditFilter, batch int, fn func([]*models.AppAudit) error) error {
+	db = a.where(db.Model(&models.AppAudit{}), filter).
+		Order("create_time desc, id desc").
+		Session(&gorm.Session{})
+	for offset := 0; ; offset += batch {
+		list := make([]*models.AppAudit, 0, batch)
+		if err := db.Limit(batch).Offset(offset).Find(&list).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		if err := fn(list); err != nil {
+			return err
+		}
+		if len(list) < batch {
+			return nil
+		}
+	}
+}
+
+func (a *appAuditRepo) where(db *gorm.DB, filter *models.AuditFilter) *gorm.DB {
+	if filter.TargetType != "" {
+		db = db.Where("target_type=?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		db = db.Where("target_id=?", filter.TargetID)
+	}
+	if filter.ActorID != "" {
+		db = db.Where("actor_id=?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		db = db.Where("action=?", filter.Action)
+	}
+	if filter.Begin != 0 {
+		db = db.Where("create_time>=?", filter.Begin)
+	}
+	if filter.End != 0 {
+		db = db.Where("create_time<=?", filter.End)
+	}
+	return db
+}
+
+//NewAppAuditRepo init repo
+func NewAppAuditRepo() models.AppAuditRepo {
+	return &appAuditRepo{}
+}
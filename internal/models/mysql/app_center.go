@@ -17,6 +17,7 @@ import (
 	"github.com/quanxiang-cloud/appcenter/internal/models"
 	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type appCenterRepo struct {
@@ -49,6 +50,15 @@ func (u appCenterRepo) UpdateDelFlag(db *gorm.DB, id string, deleteTime int64) e
 			"delete_time": deleteTime,
 		}).Error
 }
+// Restore take the app out of the recycle bin
+func (u appCenterRepo) Restore(db *gorm.DB, id string) error {
+	return db.Model(&models.AppCenter{}).Where("id=?", id).Updates(
+		map[string]interface{}{
+			"del_flag":    0,
+			"delete_time": 0,
+		}).Error
+}
+
 func (u appCenterRepo) ChangePerPoly(db *gorm.DB, id string, perPoly bool) error {
 	return db.Model(&models.AppCenter{}).Where("id=?", id).Updates(
 		map[string]interface{}{
@@ -69,6 +79,13 @@ func (u appCenterRepo) Update(rq *models.AppCenter, tx *gorm.DB) (err error) {
 	return err
 }
 
+func (u appCenterRepo) UpdateStatus(db *gorm.DB, app *models.AppCenter, from int) (bool, error) {
+	ret := db.Model(app).Where("use_status=?", from).
+		Select("use_status", "server", "update_by", "update_time").
+		Updates(app)
+	return ret.RowsAffected > 0, ret.Error
+}
+
 func (u appCenterRepo) Delete(id string, tx *gorm.DB) (err error) {
 	err = tx.Where("id=?", id).Delete(&models.AppCenter{}).Error
 	return err
@@ -102,6 +119,30 @@ func (u appCenterRepo) SelectByPage(userID, name string, status, page, limit int
 	return nil, 0
 }
 
+func (u appCenterRepo) SelectDeletedByPage(userID, name string, page, limit int, onlyAdminOf bool, db *gorm.DB) (list []models.AppCenter, total int64) {
+	if name != "" {
+		db = db.Where("app_name like ?", "%"+name+"%")
+	}
+	if onlyAdminOf {
+		db = db.Where("create_by=? or id in (select app_id from t_app_tombstone where JSON_CONTAINS(admins, JSON_QUOTE(?)))", userID, userID)
+	}
+	db = db.Where("del_flag = 1")
+	db = db.Order("delete_time asc")
+	res := make([]models.AppCenter, 0)
+	var num int64
+	db.Model(&models.AppCenter{}).Count(&num)
+	newPage := page2.NewPage(page, limit, num)
+
+	db = db.Limit(newPage.PageSize).Offset(newPage.StartIndex)
+
+	affected := db.Find(&res).RowsAffected
+	if affected > 0 {
+		return res, num
+	}
+
+	return nil, 0
+}
+
 func (u appCenterRepo) SelectByStatus(db *gorm.DB, status int, page, limit int) (list []models.AppCenter, total int64) {
 	db = db.Where("use_status=?", status)
 	db = db.Where("del_flag = 0")
@@ -130,6 +171,16 @@ func (u appCenterRepo) SelectByID(id string, db *gorm.DB) (res *models.AppCenter
 	return nil
 }
 
+// SelectForUpdate select the app and lock it until db commits
+func (u appCenterRepo) SelectForUpdate(db *gorm.DB, id string) *models.AppCenter {
+	app := models.AppCenter{}
+	affected := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).Find(&app).RowsAffected
+	if affected == 1 {
+		return &app
+	}
+	return nil
+}
+
 func (u appCenterRepo) SelectByName(name string, db *gorm.DB) (res *models.AppCenter) {
 	app := models.AppCenter{}
 	affected := db.Where("app_name=?", name).Where("del_flag = 0").Find(&app).RowsAffected
@@ -0,0 +1,49 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"gorm.io/gorm"
+)
+
+type appOutboxRepo struct {
+}
+
+func (a *appOutboxRepo) Insert(db *gorm.DB, event *models.AppOutbox) error {
+	return db.Create(event).Error
+}
+
+func (a *appOutboxRepo) ListPending(db *gorm.DB, limit int) ([]*models.AppOutbox, error) {
+	list := make([]*models.AppOutbox, 0)
+	err := db.Where("status=?", models.OutboxPending).
+		Order("id").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+func (a *appOutboxRepo) Save(db *gorm.DB, event *models.AppOutbox) error {
+	return db.Save(event).Error
+}
+
+func (a *appOutboxRepo) DeleteRelayed(db *gorm.DB, before int64) error {
+	return db.Where("status<>? and relay_time<?", models.OutboxPending, before).
+		Delete(&models.AppOutbox{}).Error
+}
+
+//NewAppOutboxRepo init repo
+func NewAppOutboxRepo() models.AppOutboxRepo {
+	return &appOutboxRepo{}
+}
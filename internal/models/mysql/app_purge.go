@@ -0,0 +1,44 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"gorm.io/gorm"
+)
+
+type appPurgeRepo struct {
+}
+
+func (a *appPurgeRepo) SelectByAppID(db *gorm.DB, appID string) *models.AppPurge {
+	purge := models.AppPurge{}
+	affected := db.Where("app_id=?", appID).Find(&purge).RowsAffected
+	if affected > 0 {
+		return &purge
+	}
+	return nil
+}
+
+func (a *appPurgeRepo) Save(db *gorm.DB, purge *models.AppPurge) error {
+	return db.Save(purge).Error
+}
+
+func (a *appPurgeRepo) Delete(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppPurge{}).Error
+}
+
+//NewAppPurgeRepo init repo
+func NewAppPurgeRepo() models.AppPurgeRepo {
+	return &appPurgeRepo{}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+	"gorm.io/gorm"
+)
+
+type appScheduleRepo struct {
+}
+
+func (a *appScheduleRepo) Insert(db *gorm.DB, schedule *models.AppSchedule) error {
+	return db.Create(schedule).Error
+}
+
+func (a *appScheduleRepo) SelectByID(db *gorm.DB, appID, id string) *models.AppSchedule {
+	schedule := models.AppSchedule{}
+	affected := db.Where("app_id=? and id=?", appID, id).Find(&schedule).RowsAffected
+	if affected > 0 {
+		return &schedule
+	}
+	return nil
+}
+
+func (a *appScheduleRepo) List(db *gorm.DB, appID string, status, page, limit int) ([]*models.AppSchedule, int64, error) {
+	db = db.Model(&models.AppSchedule{}).Where("app_id=?", appID)
+	if status >= 0 {
+		db = db.Where("status=?", status)
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	newPage := page2.NewPage(page, limit, total)
+
+	list := make([]*models.AppSchedule, 0)
+	err := db.Order("execute_time desc, id desc").
+		Limit(newPage.PageSize).
+		Offset(newPage.StartIndex).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (a *appScheduleRepo) ListDue(db *gorm.DB, now, stale int64, limit int) ([]*models.AppSchedule, error) {
+	list := make([]*models.AppSchedule, 0)
+	err := db.Where("(status=? and execute_time<=?) or (status=? and update_time<?)",
+		models.SchedulePending, now, models.ScheduleRunning, stale).
+		Order("execute_time").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+func (a *appScheduleRepo) UpdateStatus(db *gorm.DB, schedule *models.AppSchedule, from int) (bool, error) {
+	ret := db.Model(schedule).
+		Where("status=?", from).
+		Select("status", "last_error", "update_by", "update_time").
+		Updates(schedule)
+	return ret.RowsAffected > 0, ret.Error
+}
+
+func (a *appScheduleRepo) DeleteByAppID(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppSchedule{}).Error
+}
+
+//NewAppScheduleRepo init repo
+func NewAppScheduleRepo() models.AppScheduleRepo {
+	return &appScheduleRepo{}
+}
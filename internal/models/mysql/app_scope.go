@@ -73,6 +73,15 @@ func (a *appScopeRepo) DeleteByAppID(db *gorm.DB, appID string) error {
 
 }
 
+func (a *appScopeRepo) SelectByAppID(db *gorm.DB, appID string) ([]*models.AppScope, error) {
+	appScope := make([]*models.AppScope, 0)
+	err := db.Table(a.TableName()).Where("app_id = ?", appID).Find(&appScope).Error
+	if err != nil {
+		return nil, err
+	}
+	return appScope, nil
+}
+
 //NewAppScopeRepo init repo
 func NewAppScopeRepo() models.AppScopeRepo {
 	return &appScopeRepo{}
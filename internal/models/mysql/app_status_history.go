@@ -0,0 +1,60 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+	"gorm.io/gorm"
+)
+
+type appStatusHistoryRepo struct {
+}
+
+func (a *appStatusHistoryRepo) Insert(db *gorm.DB, history *models.AppStatusHistory) error {
+	return db.Create(history).Error
+}
+
+func (a *appStatusHistoryRepo) List(db *gorm.DB, appID string, page, limit int) ([]*models.AppStatusHistory, int64, error) {
+	db = db.Model(&models.AppStatusHistory{}).Where("app_id=?", appID).Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	newPage := page2.NewPage(page, limit, total)
+
+	list := make([]*models.AppStatusHistory, 0)
+	err := db.Order("id desc").
+		Limit(newPage.PageSize).
+		Offset(newPage.StartIndex).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (a *appStatusHistoryRepo) ListSince(db *gorm.DB, appID string, since int64) ([]*models.AppStatusHistory, error) {
+	list := make([]*models.AppStatusHistory, 0)
+	err := db.Where("app_id=? and create_time>=?", appID, since).
+		Order("id").
+		Find(&list).Error
+	return list, err
+}
+
+func (a *appStatusHistoryRepo) DeleteByAppID(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppStatusHistory{}).Error
+}
+
+//NewAppStatusHistoryRepo init repo
+func NewAppStatusHistoryRepo() models.AppStatusHistoryRepo {
+	return &appStatusHistoryRepo{}
+}
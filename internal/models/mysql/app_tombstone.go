@@ -0,0 +1,44 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	"gorm.io/gorm"
+)
+
+type appTombstoneRepo struct {
+}
+
+func (a *appTombstoneRepo) SelectByAppID(db *gorm.DB, appID string) *models.AppTombstone {
+	tombstone := models.AppTombstone{}
+	affected := db.Where("app_id=?", appID).Find(&tombstone).RowsAffected
+	if affected > 0 {
+		return &tombstone
+	}
+	return nil
+}
+
+func (a *appTombstoneRepo) Save(db *gorm.DB, tombstone *models.AppTombstone) error {
+	return db.Save(tombstone).Error
+}
+
+func (a *appTombstoneRepo) Delete(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppTombstone{}).Error
+}
+
+//NewAppTombstoneRepo init repo
+func NewAppTombstoneRepo() models.AppTombstoneRepo {
+	return &appTombstoneRepo{}
+}
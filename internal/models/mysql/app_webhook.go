@@ -0,0 +1,134 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/quanxiang-cloud/appcenter/internal/models"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type appWebhookRepo struct {
+}
+
+func (a *appWebhookRepo) Insert(db *gorm.DB, hook *models.AppWebhook) error {
+	return db.Create(hook).Error
+}
+
+func (a *appWebhookRepo) Update(db *gorm.DB, hook *models.AppWebhook) error {
+	return db.Model(hook).
+		Select("url", "events", "enabled", "update_by", "update_time").
+		Updates(hook).Error
+}
+
+func (a *appWebhookRepo) Delete(db *gorm.DB, appID, id string) error {
+	return db.Where("app_id=? and id=?", appID, id).Delete(&models.AppWebhook{}).Error
+}
+
+func (a *appWebhookRepo) SelectByID(db *gorm.DB, appID, id string) *models.AppWebhook {
+	hook := models.AppWebhook{}
+	affected := db.Where("app_id=? and id=?", appID, id).Find(&hook).RowsAffected
+	if affected > 0 {
+		return &hook
+	}
+	return nil
+}
+
+func (a *appWebhookRepo) SelectByAppID(db *gorm.DB, appID string) ([]*models.AppWebhook, error) {
+	list := make([]*models.AppWebhook, 0)
+	err := db.Where("app_id=?", appID).Find(&list).Error
+	return list, err
+}
+
+func (a *appWebhookRepo) List(db *gorm.DB, appID string, page, limit int) ([]*models.AppWebhook, int64, error) {
+	db = db.Model(&models.AppWebhook{}).Where("app_id=?", appID).Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	newPage := page2.NewPage(page, limit, total)
+
+	list := make([]*models.AppWebhook, 0)
+	err := db.Order("create_time desc").
+		Limit(newPage.PageSize).
+		Offset(newPage.StartIndex).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (a *appWebhookRepo) DeleteByAppID(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppWebhook{}).Error
+}
+
+//NewAppWebhookRepo init repo
+func NewAppWebhookRepo() models.AppWebhookRepo {
+	return &appWebhookRepo{}
+}
+
+type appWebhookDeliveryRepo struct {
+}
+
+func (a *appWebhookDeliveryRepo) Insert(db *gorm.DB, deliveries ...*models.AppWebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+func (a *appWebhookDeliveryRepo) Save(db *gorm.DB, delivery *models.AppWebhookDelivery) error {
+	return db.Save(delivery).Error
+}
+
+func (a *appWebhookDeliveryRepo) ListDue(db *gorm.DB, now int64, limit int) ([]*models.AppWebhookDelivery, error) {
+	list := make([]*models.AppWebhookDelivery, 0)
+	err := db.Where("status=? and next_time<=?", models.DeliveryPending, now).
+		Order("next_time").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+func (a *appWebhookDeliveryRepo) List(db *gorm.DB, appID, webhookID string, page, limit int) ([]*models.AppWebhookDelivery, int64, error) {
+	db = db.Model(&models.AppWebhookDelivery{}).Where("app_id=?", appID)
+	if webhookID != "" {
+		db = db.Where("webhook_id=?", webhookID)
+	}
+	db = db.Session(&gorm.Session{})
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	newPage := page2.NewPage(page, limit, total)
+
+	list := make([]*models.AppWebhookDelivery, 0)
+	err := db.Order("create_time desc, id desc").
+		Limit(newPage.PageSize).
+		Offset(newPage.StartIndex).
+		Find(&list).Error
+	return list, total, err
+}
+
+func (a *appWebhookDeliveryRepo) DeleteByWebhookID(db *gorm.DB, webhookID string) error {
+	return db.Where("webhook_id=?", webhookID).Delete(&models.AppWebhookDelivery{}).Error
+}
+
+func (a *appWebhookDeliveryRepo) DeleteByAppID(db *gorm.DB, appID string) error {
+	return db.Where("app_id=?", appID).Delete(&models.AppWebhookDelivery{}).Error
+}
+
+//NewAppWebhookDeliveryRepo init repo
+func NewAppWebhookDeliveryRepo() models.AppWebhookDeliveryRepo {
+	return &appWebhookDeliveryRepo{}
+}
@@ -42,7 +42,8 @@ type UpdateAppCenter struct {
 
 // DelAppCenter DelAppCenter
 type DelAppCenter struct {
-	ID string `json:"id" binding:"required,max=64"`
+	ID       string `json:"id" binding:"required,max=64"`
+	DeleteBy string `json:"-"`
 }
 
 // SelectListAppCenter SelectListAppCenter
@@ -89,6 +90,7 @@ type CheckIsAdminReq struct {
 	AppID   string `json:"appID"`
 	UserID  string `json:"userID"`
 	IsSuper bool   `json:"is_super"`
+	Deleted bool   `json:"-"` // check the app in the recycle bin
 }
 
 // AddAppScopeReq AddAppScopeReq
@@ -156,6 +158,13 @@ type InitServerReq struct {
 	Server   int    `json:"server"`
 }
 
+// StatusHistoryReq StatusHistoryReq
+type StatusHistoryReq struct {
+	AppID string `json:"appID" binding:"required"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
 // ListAppByStatusReq ListAppByStatusReq
 type ListAppByStatusReq struct {
 	Status int `json:"status"`
@@ -173,3 +182,23 @@ type HomeAccessListReq struct {
 	Page  int `json:"page" binding:"required"`
 	Size  int `json:"size" binding:"required"`
 }
+
+// SelectRecycleList SelectRecycleList
+type SelectRecycleList struct {
+	AppName string `json:"appName" binding:"max=80,excludesall=0x2C!@#$?.%:*&^+><=；;"`
+	Page    int    `json:"page"`
+	Limit   int    `json:"limit"`
+	UserID  string `json:"-"`
+	IsSuper bool   `json:"-"`
+}
+
+// RestoreAppReq RestoreAppReq
+type RestoreAppReq struct {
+	ID       string `json:"id" binding:"required,max=64"`
+	UpdateBy string `json:"-"`
+}
+
+// PurgeAppReq PurgeAppReq
+type PurgeAppReq struct {
+	ID string `json:"id" binding:"required,max=64"`
+}
@@ -0,0 +1,27 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package req
+
+// ListAuditReq ListAuditReq
+type ListAuditReq struct {
+	TargetType string `json:"targetType" binding:"omitempty,oneof=app template"`
+	TargetID   string `json:"targetID"`
+	ActorID    string `json:"actorID"`
+	Action     string `json:"action"`
+	// Begin, End range of the create time in unix seconds, zero is unbounded
+	Begin int64 `json:"begin"`
+	End   int64 `json:"end"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+}
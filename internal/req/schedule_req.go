@@ -0,0 +1,40 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package req
+
+// CreateScheduleReq CreateScheduleReq
+type CreateScheduleReq struct {
+	AppID string `json:"appID" binding:"required"`
+	// UseStatus published:1, unpublished:-1
+	UseStatus int `json:"useStatus" binding:"required,oneof=1 -1"`
+	// ExecuteTime unix seconds the app changes its use status
+	ExecuteTime int64  `json:"executeTime" binding:"required"`
+	UserID      string `json:"-"`
+}
+
+// ListScheduleReq ListScheduleReq
+type ListScheduleReq struct {
+	AppID string `json:"appID" binding:"required"`
+	// Status the schedules of any status if it is not set
+	Status *int `json:"status"`
+	Page   int  `json:"page"`
+	Limit  int  `json:"limit"`
+}
+
+// CancelScheduleReq CancelScheduleReq
+type CancelScheduleReq struct {
+	ID     string `json:"id" binding:"required"`
+	AppID  string `json:"appID" binding:"required"`
+	UserID string `json:"-"`
+}
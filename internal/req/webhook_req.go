@@ -0,0 +1,61 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package req
+
+// CreateWebhookReq CreateWebhookReq
+type CreateWebhookReq struct {
+	AppID  string   `json:"appID" binding:"required"`
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=app.published app.unpublished app.scope.changed app.deleted"`
+	// Secret key of the signature, it is generated if it is empty
+	Secret string `json:"secret"`
+	UserID string `json:"-"`
+}
+
+// UpdateWebhookReq UpdateWebhookReq
+type UpdateWebhookReq struct {
+	ID      string   `json:"id" binding:"required"`
+	AppID   string   `json:"appID" binding:"required"`
+	URL     string   `json:"url" binding:"required,url"`
+	Events  []string `json:"events" binding:"required,min=1,dive,oneof=app.published app.unpublished app.scope.changed app.deleted"`
+	Enabled bool     `json:"enabled"`
+	UserID  string   `json:"-"`
+}
+
+// DeleteWebhookReq DeleteWebhookReq
+type DeleteWebhookReq struct {
+	ID    string `json:"id" binding:"required"`
+	AppID string `json:"appID" binding:"required"`
+}
+
+// ListWebhookReq ListWebhookReq
+type ListWebhookReq struct {
+	AppID string `json:"appID" binding:"required"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+// ListDeliveryReq ListDeliveryReq
+type ListDeliveryReq struct {
+	AppID     string `json:"appID" binding:"required"`
+	WebhookID string `json:"webhookID"`
+	Page      int    `json:"page"`
+	Limit     int    `json:"limit"`
+}
+
+// TestWebhookReq TestWebhookReq
+type TestWebhookReq struct {
+	ID    string `json:"id" binding:"required"`
+	AppID string `json:"appID" binding:"required"`
+}
@@ -34,6 +34,17 @@ type AdminAppCenter struct {
 	PerPoly     bool                   `json:"perPoly"`
 }
 
+// RecycleAppCenter RecycleAppCenter
+type RecycleAppCenter struct {
+	ID         string `json:"id"`
+	AppName    string `json:"appName"`
+	AppIcon    string `json:"appIcon"`
+	CreateBy   string `json:"createBy"`
+	UpdateBy   string `json:"updateBy"`
+	UpdateTime int64  `json:"updateTime"`
+	DeleteTime int64  `json:"deleteTime"` // the app is purged after it
+}
+
 // UserAppCenter UserAppCenter
 type UserAppCenter struct {
 	ID          string                 `json:"id,omitempty"`
@@ -109,3 +120,11 @@ type HomeAccessListResp struct {
 	List  []models.Scope `json:"list"`
 	Total int64          `json:"total"`
 }
+
+// RestoreAppResp RestoreAppResp
+type RestoreAppResp struct {
+}
+
+// PurgeAppResp PurgeAppResp
+type PurgeAppResp struct {
+}
@@ -0,0 +1,37 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resp
+
+// CreateWebhookResp CreateWebhookResp
+type CreateWebhookResp struct {
+	ID string `json:"id"`
+	// Secret key of the signature, it is not returned again
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhookResp UpdateWebhookResp
+type UpdateWebhookResp struct {
+}
+
+// DeleteWebhookResp DeleteWebhookResp
+type DeleteWebhookResp struct {
+}
+
+// TestWebhookResp TestWebhookResp
+type TestWebhookResp struct {
+	DeliveryID   string `json:"deliveryID"`
+	Delivered    bool   `json:"delivered"`
+	ResponseCode int    `json:"responseCode"`
+	Error        string `json:"error,omitempty"`
+}
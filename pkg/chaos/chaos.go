@@ -1,13 +1,17 @@
 package chaos
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/gin-gonic/gin"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/page"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	error2 "github.com/quanxiang-cloud/cabin/error"
 	"github.com/quanxiang-cloud/cabin/logger"
-	"github.com/quanxiang-cloud/cabin/tailormade/header"
 	"github.com/quanxiang-cloud/cabin/tailormade/resp"
 )
 
@@ -24,6 +28,13 @@ type InitReq struct {
 	Limit  int `json:"limit"`
 }
 
+// TasksReq query of the tasks, a page is at most Limit tasks
+type TasksReq struct {
+	State string `form:"state"`
+	Page  int    `form:"page"`
+	Limit int    `form:"limit"`
+}
+
 // New New
 func New(c *config.Configs, handler *handle.TaskHandler, log logger.AdaptedLogger) (*Chaos, error) {
 	chaos := &Chaos{
@@ -37,22 +48,197 @@ func New(c *config.Configs, handler *handle.TaskHandler, log logger.AdaptedLogge
 	return chaos, nil
 }
 
-type initResp struct{}
-
-// Handle Handle
+// Handle put the messages into the queue, and return the task of each message.
+// Nothing is put if a message is invalid, the messages after a failed put are
+// not accepted, the accepted ones are reported with the error.
 func (p *Chaos) Handle(c *gin.Context) {
 	msgs := make([]define.Msg, 0)
 	if err := c.ShouldBind(&msgs); err != nil {
-		resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, err.Error()))
+		resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, err.Error())).Context(c)
+		return
+	}
+	for i, msg := range msgs {
+		if err := p.handler.Validate(msg); err != nil {
+			resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, fmt.Sprintf("message %d: %s", i, err.Error()))).Context(c)
+			return
+		}
+	}
+
+	ctx := tracing.MutateContext(c)
+	ret := define.Response{
+		Tasks: make([]define.Accepted, len(msgs)),
+	}
+	var failed error
+	for i, msg := range msgs {
+		ret.Tasks[i].AppID = msg.AppID
+		if failed != nil {
+			ret.Tasks[i].Error = "not accepted"
+			continue
+		}
+		id, coalesced, err := p.handler.Put(ctx, msg)
+		if err != nil {
+			failed = fmt.Errorf("%d of %d messages are accepted, message %d: %s", i, len(msgs), i, err.Error())
+			ret.Tasks[i].Error = err.Error()
+			continue
+		}
+		ret.Tasks[i].TaskID = id
+		ret.Tasks[i].Coalesced = coalesced
+	}
+	if failed != nil {
+		p.log.Error(failed.Error())
+		r := resp.Format(nil, error2.NewErrorWithString(error2.Internal, failed.Error()))
+		r.Data = ret
+		r.Context(c)
+		return
+	}
+	resp.Format(ret, nil).Context(c)
+}
+
+// Tasks list a page of the status of the tasks of the app in path,
+// or the tasks in the state of query.
+func (p *Chaos) Tasks(c *gin.Context) {
+	appID := c.Param("appID")
+	rq := &TasksReq{}
+	if err := c.ShouldBindQuery(rq); err != nil || rq.Page < 0 || rq.Limit < 0 {
+		resp.Format(nil, error2.New(error2.ErrParams)).Context(c)
+		return
+	}
+	state := rq.State
+	if state != "" && !handle.IsState(state) {
+		resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, "unknown state "+state)).Context(c)
+		return
+	}
+	if appID == "" && state == "" {
+		resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, "appID or state is required")).Context(c)
+		return
+	}
+
+	list, total, err := p.handler.Tasks(appID, state, rq.Page, rq.Limit)
+	if err != nil {
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	resp.Format(&page.Page{
+		Data:       list,
+		TotalCount: total,
+	}, nil).Context(c)
+}
+
+// Events stream the progress of the tasks of the app in path over server-sent events,
+// it ends when the task is finished or the client goes away.
+func (p *Chaos) Events(c *gin.Context) {
+	appID := c.Param("appID")
+	// subscribe before the snapshot, no event between them is lost
+	events, cancel := p.handler.Subscribe(appID)
+	defer cancel()
+
+	// the latest task only
+	list, _, err := p.handler.Tasks(appID, "", 1, 1)
+	if err != nil {
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if len(list) != 0 {
+		latest := list[0]
+		e := define.Event{
+			Type:   define.EventState,
+			TaskID: latest.ID,
+			AppID:  latest.AppID,
+			State:  latest.State,
+			Ret:    latest.Ret,
+			Error:  latest.LastError,
+			Final:  handle.IsFinished(latest.State),
+			Time:   latest.UpdateTime,
+		}
+		c.SSEvent(e.Type, e)
+		c.Writer.Flush()
+		if e.Final {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(e.Type, e)
+			c.Writer.Flush()
+			if e.Final {
+				return
+			}
+		}
+	}
+}
+
+// DeadLetters list the tasks which used up the retries.
+func (p *Chaos) DeadLetters(c *gin.Context) {
+	list, err := p.handler.DeadLetters()
+	if err != nil {
+		resp.Format(nil, err).Context(c)
 		return
 	}
+	resp.Format(&page.Page{
+		Data:       list,
+		TotalCount: int64(len(list)),
+	}, nil).Context(c)
+}
 
-	ctx := header.MutateContext(c)
-	for _, msg := range msgs {
-		if err := p.handler.Put(ctx, msg); err != nil {
-			resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, err.Error()))
+// DeadLetter return the dead letter of id in path.
+func (p *Chaos) DeadLetter(c *gin.Context) {
+	dl, err := p.handler.DeadLetter(c.Param("id"))
+	if err != nil {
+		resp.Format(nil, err).Context(c)
+		return
+	}
+	if dl == nil {
+		resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, handle.ErrDeadLetterNotFound.Error())).Context(c)
+		return
+	}
+	resp.Format(dl, nil).Context(c)
+}
+
+// ReplayReq ReplayReq
+type ReplayReq struct {
+	// Content bits of the executors to run again, the failed ones are run if it is zero
+	Content int `json:"content"`
+}
+
+type replayResp struct{}
+
+// Replay put the dead letter of id in path back into the queue.
+func (p *Chaos) Replay(c *gin.Context) {
+	rq := &ReplayReq{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBind(rq); err != nil {
+			resp.Format(nil, error2.NewErrorWithString(error2.ErrParams, err.Error())).Context(c)
 			return
 		}
 	}
-	resp.Format(initResp{}, nil).Context(c)
+	if err := p.handler.Replay(c.Param("id"), rq.Content); err != nil {
+		resp.Format(nil, deadLetterErr(err)).Context(c)
+		return
+	}
+	resp.Format(replayResp{}, nil).Context(c)
+}
+
+type discardResp struct{}
+
+// Discard remove the dead letter of id in path.
+func (p *Chaos) Discard(c *gin.Context) {
+	if err := p.handler.Discard(c.Param("id")); err != nil {
+		resp.Format(nil, deadLetterErr(err)).Context(c)
+		return
+	}
+	resp.Format(discardResp{}, nil).Context(c)
+}
+
+func deadLetterErr(err error) error {
+	if err == handle.ErrDeadLetterNotFound || errors.Is(err, handle.ErrUnknownBits) {
+		return error2.NewErrorWithString(error2.ErrParams, err.Error())
+	}
+	return err
 }
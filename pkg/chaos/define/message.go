@@ -1,5 +1,7 @@
 package define
 
+import "fmt"
+
 // Msg msg
 type Msg struct {
 	AppID    string `json:"appID"`
@@ -9,4 +11,49 @@ type Msg struct {
 }
 
 // Response response
-type Response struct{}
+type Response struct {
+	Tasks []Accepted `json:"tasks"`
+}
+
+// Accepted the task of a message, in the order of the messages
+type Accepted struct {
+	AppID  string `json:"appID"`
+	TaskID string `json:"taskID,omitempty"`
+	// Coalesced the message joined an in-flight task with the same content
+	Coalesced bool   `json:"coalesced,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatusError the server responded with an unexpected status code
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("expected state value is 200, actually %d", e.Code)
+}
+
+// type of Event
+const (
+	// EventState the state of the task changed
+	EventState = "state"
+	// EventExecutor an executor finished
+	EventExecutor = "executor"
+	// EventAsync the watch ends before the task, the task goes on asynchronously
+	EventAsync = "async"
+)
+
+// Event progress of the initialization of an app
+type Event struct {
+	Type   string `json:"type"`
+	TaskID string `json:"taskID,omitempty"`
+	AppID  string `json:"appID"`
+	State  string `json:"state,omitempty"`
+	// Bit the bit of the executor of EventExecutor
+	Bit   int    `json:"bit,omitempty"`
+	Ret   int    `json:"ret"`
+	Error string `json:"error,omitempty"`
+	// Final the task is finished, no more events
+	Final bool  `json:"final"`
+	Time  int64 `json:"time"`
+}
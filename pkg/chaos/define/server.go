@@ -2,7 +2,13 @@ package define
 
 // Bit of server
 const (
-	BitAways   = 0
-	BitFormAPI = 1 << 0 // 01
-	BitPolyAPI = 1 << 1 // 10
+	BitAways    = 0
+	BitFormAPI  = 1 << 0 // 01
+	BitPolyAPI  = 1 << 1 // 10
+	BitFlow     = 1 << 2 // 100
+	BitStructor = 1 << 3 // 1000
 )
+
+// StatusUnReady use status of the apps whose servers are being initialized,
+// the apps of it are initialized again when chaos starts.
+const StatusUnReady = -5
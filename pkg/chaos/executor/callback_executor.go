@@ -13,6 +13,8 @@ const (
 	InitBack = "init-back"
 )
 
+type callbackResp struct{}
+
 // BaseExecutor BaseExecutor
 type BaseExecutor struct {
 	Client       http.Client
@@ -33,7 +35,7 @@ func (b *BaseExecutor) Exec(ctx context.Context, m define.Msg) error {
 		Ret:    m.Ret,
 	}
 
-	resp := &define.Response{}
+	resp := &callbackResp{}
 	err := client.POST(ctx, &b.Client, b.AppCenterURL, req, resp)
 	if err != nil {
 		return err
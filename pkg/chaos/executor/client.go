@@ -5,11 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	error2 "github.com/quanxiang-cloud/cabin/error"
 	"github.com/quanxiang-cloud/cabin/tailormade/header"
 	"github.com/quanxiang-cloud/cabin/tailormade/resp"
 )
@@ -26,7 +27,7 @@ func post(ctx context.Context, client *http.Client, uri string, params interface
 	}
 
 	reader := bytes.NewReader(paramByte)
-	req, err := http.NewRequest("POST", uri, reader)
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, reader)
 	if err != nil {
 		return err
 	}
@@ -43,7 +44,7 @@ func post(ctx context.Context, client *http.Client, uri string, params interface
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("expected state value is 200, actually %d", response.StatusCode)
+		return &define.StatusError{Code: response.StatusCode}
 	}
 
 	body, err := ioutil.ReadAll(response.Body)
@@ -63,5 +64,9 @@ func decomposeBody(body []byte, entity interface{}) error {
 		return err
 	}
 
+	// the downstream answers its errors with status 200 and a code
+	if r.Code != error2.Success {
+		return r.Error
+	}
 	return nil
 }
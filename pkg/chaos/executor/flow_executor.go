@@ -0,0 +1,69 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/cabin/logger"
+)
+
+// Key
+const (
+	FlowInit = "flow-init"
+)
+
+const (
+	flowDelStatus = "delete"
+
+	// flowInitPath the route of the initialization on the flow host, %s is the app id
+	flowInitPath = "/api/v1/flow/%s/initApp"
+)
+
+// FlowExecutor FlowExecutor
+type FlowExecutor struct {
+	Client http.Client
+	// InitURL the url of the initialization, %s is the app id
+	InitURL string
+	// Flow removes the app from flow on compensation
+	Flow client.Flow
+}
+
+type initFlowReq struct {
+	AppID    string `json:"appID"`
+	CreateBy string `json:"createBy"`
+}
+
+type initFlowResp struct{}
+
+// Exec Exec
+func (f *FlowExecutor) Exec(ctx context.Context, m define.Msg) error {
+	flowReq := &initFlowReq{
+		AppID:    m.AppID,
+		CreateBy: m.CreateBy,
+	}
+	flowResp := &initFlowResp{}
+	if err := post(ctx, &f.Client, fmt.Sprintf(f.InitURL, m.AppID), flowReq, flowResp); err != nil {
+		logger.Logger.Errorf("init flow url: %s", fmt.Sprintf(f.InitURL, m.AppID))
+		logger.Logger.Errorf("init flow: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Compensate remove the app from flow
+func (f *FlowExecutor) Compensate(ctx context.Context, m define.Msg) error {
+	resp, err := f.Flow.RemoveApp(ctx, m.AppID, flowDelStatus)
+	if err = client.CheckDelResp(resp, err); err != nil {
+		logger.Logger.Errorf("compensate flow: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Bit Bit
+func (*FlowExecutor) Bit() int {
+	return define.BitFlow
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
 	"github.com/quanxiang-cloud/cabin/logger"
 )
 
@@ -13,6 +14,8 @@ import (
 const (
 	FormCreateRole = "form-role"
 	FormAssignRole = "form-assign"
+	FormFindRole   = "form-find-role"
+	FormDeleteRole = "form-delete-role"
 
 // FormHost   = "form"
 // CreateRole = "/api/v1/form/%s/internal/apiRole/create"
@@ -22,6 +25,9 @@ const (
 const (
 	name        = "全部权限"
 	description = "系统默认角色"
+
+	// formRoleState the state of the task with the id of the role created by it
+	formRoleState = "form.roleID"
 )
 
 // FormExecutor FormExecutor
@@ -29,6 +35,8 @@ type FormExecutor struct {
 	Client     http.Client
 	CreateRole string
 	AssignRole string
+	FindRole   string
+	DeleteRole string
 }
 
 type createRoleReq struct {
@@ -41,6 +49,23 @@ type createRoleResp struct {
 	RoleID string `json:"id"`
 }
 
+type findRoleReq struct {
+	Name  string `json:"name"`
+	Types int    `json:"types"`
+}
+
+type findRoleResp struct {
+	List []*role `json:"list"`
+}
+
+type role struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Types int    `json:"types"`
+}
+
+type deleteRoleResp struct{}
+
 type assignReq struct {
 	Add []*user `json:"add"`
 }
@@ -54,19 +79,33 @@ type user struct {
 
 // Exec Exec
 func (s *FormExecutor) Exec(ctx context.Context, m define.Msg) error {
-	roleReq := &createRoleReq{
-		Name:        name,
-		Description: description,
-		Types:       1,
-	}
-	roleResp := &createRoleResp{}
-	if err := post(ctx, &s.Client, fmt.Sprintf(s.CreateRole, m.AppID), roleReq, roleResp); err != nil {
-		logger.Logger.Errorf("init form url: %s", fmt.Sprintf(s.CreateRole, m.AppID))
-		logger.Logger.Errorf("init form: %s", err)
-		return err
+	// a retry reuses the role created by the last attempt of the task,
+	// another task of the app reuses the role of the tasks before it
+	roleID := handle.State(ctx, formRoleState)
+	if roleID == "" {
+		var err error
+		roleID, err = s.findRole(ctx, m.AppID)
+		if err != nil {
+			return err
+		}
 	}
-	if roleResp.RoleID == "" {
-		return nil
+	if roleID == "" {
+		roleReq := &createRoleReq{
+			Name:        name,
+			Description: description,
+			Types:       1,
+		}
+		roleResp := &createRoleResp{}
+		if err := post(ctx, &s.Client, fmt.Sprintf(s.CreateRole, m.AppID), roleReq, roleResp); err != nil {
+			logger.Logger.Errorf("init form url: %s", fmt.Sprintf(s.CreateRole, m.AppID))
+			logger.Logger.Errorf("init form: %s", err)
+			return err
+		}
+		if roleResp.RoleID == "" {
+			return nil
+		}
+		roleID = roleResp.RoleID
+		handle.SetState(ctx, formRoleState, roleID)
 	}
 
 	assignReq := &assignReq{
@@ -78,8 +117,8 @@ func (s *FormExecutor) Exec(ctx context.Context, m define.Msg) error {
 		},
 	}
 	assignResp := &assignResp{}
-	if err := post(ctx, &s.Client, fmt.Sprintf(s.AssignRole, m.AppID, roleResp.RoleID), assignReq, assignResp); err != nil {
-		logger.Logger.Errorf("init form url: %s", fmt.Sprintf(s.AssignRole, m.AppID, roleResp.RoleID))
+	if err := post(ctx, &s.Client, fmt.Sprintf(s.AssignRole, m.AppID, roleID), assignReq, assignResp); err != nil {
+		logger.Logger.Errorf("init form url: %s", fmt.Sprintf(s.AssignRole, m.AppID, roleID))
 		logger.Logger.Errorf("init form: %s", err)
 		return err
 	}
@@ -87,6 +126,41 @@ func (s *FormExecutor) Exec(ctx context.Context, m define.Msg) error {
 	return nil
 }
 
+// Compensate remove the default role created by the task, the roles of the tasks before it are kept
+func (s *FormExecutor) Compensate(ctx context.Context, m define.Msg) error {
+	roleID := handle.State(ctx, formRoleState)
+	if roleID == "" {
+		return nil
+	}
+	if err := post(ctx, &s.Client, fmt.Sprintf(s.DeleteRole, m.AppID, roleID), struct{}{}, &deleteRoleResp{}); err != nil {
+		logger.Logger.Errorf("compensate form url: %s", fmt.Sprintf(s.DeleteRole, m.AppID, roleID))
+		logger.Logger.Errorf("compensate form: %s", err)
+		return err
+	}
+	handle.SetState(ctx, formRoleState, "")
+	return nil
+}
+
+// findRole return the id of the default role of the app, or empty if it does not exist.
+func (s *FormExecutor) findRole(ctx context.Context, appID string) (string, error) {
+	findReq := &findRoleReq{
+		Name:  name,
+		Types: 1,
+	}
+	findResp := &findRoleResp{}
+	if err := post(ctx, &s.Client, fmt.Sprintf(s.FindRole, appID), findReq, findResp); err != nil {
+		logger.Logger.Errorf("find form role url: %s", fmt.Sprintf(s.FindRole, appID))
+		logger.Logger.Errorf("find form role: %s", err)
+		return "", err
+	}
+	for _, r := range findResp.List {
+		if r.Name == name && r.Types == 1 {
+			return r.ID, nil
+		}
+	}
+	return "", nil
+}
+
 // Bit Bit
 func (*FormExecutor) Bit() int {
 	return define.BitFormAPI
@@ -5,6 +5,8 @@ import (
 
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
+	client2 "github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/cabin/logger"
 	"github.com/quanxiang-cloud/cabin/tailormade/client"
 )
 
@@ -37,11 +39,11 @@ var (
 // InitExec InitExec
 func InitExec(handler *handle.TaskHandler) error {
 	c := handler.Config
-	cli := client.New(c.InternalNet)
+	cli := client2.NewHTTPClient(c.InternalNet)
 	ctx := context.WithValue(context.Background(), requestID, initChaos)
 
 	req := &listReq{
-		Status: -5,
+		Status: define.StatusUnReady,
 		Page:   0,
 		Limit:  100,
 	}
@@ -55,10 +57,12 @@ func InitExec(handler *handle.TaskHandler) error {
 		}
 
 		for _, app := range resp.Data {
-			handler.Put(ctx, define.Msg{
+			if _, _, err := handler.Put(ctx, define.Msg{
 				AppID:    app.ID,
 				CreateBy: app.CreateBy,
-			})
+			}); err != nil {
+				logger.Logger.Errorf("put app %s is error %s", app.ID, err.Error())
+			}
 		}
 
 		resp.Data = resp.Data[:0]
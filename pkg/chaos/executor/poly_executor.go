@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/client"
 	"github.com/quanxiang-cloud/cabin/logger"
 )
 
@@ -17,6 +18,9 @@ const (
 type PolyExecutor struct {
 	Client  http.Client
 	PolyURL string
+	// PolyAPI removes the app from polyapi on compensation,
+	// the app path is kept without it
+	PolyAPI client.PolyAPI
 }
 
 type initPolyReq struct {
@@ -27,6 +31,8 @@ type initAppPath struct {
 	AppID string `json:"appID"`
 }
 
+type initPolyResp struct{}
+
 // Exec Exec
 func (p *PolyExecutor) Exec(ctx context.Context, m define.Msg) error {
 	polyReq := &initPolyReq{
@@ -34,7 +40,7 @@ func (p *PolyExecutor) Exec(ctx context.Context, m define.Msg) error {
 			AppID: m.AppID,
 		},
 	}
-	polyResp := &define.Response{}
+	polyResp := &initPolyResp{}
 	if err := post(ctx, &p.Client, p.PolyURL, polyReq, polyResp); err != nil {
 		logger.Logger.Errorf("init polyapi url: %s", p.PolyURL)
 		logger.Logger.Errorf("%s", err)
@@ -43,6 +49,19 @@ func (p *PolyExecutor) Exec(ctx context.Context, m define.Msg) error {
 	return nil
 }
 
+// Compensate remove the app from polyapi
+func (p *PolyExecutor) Compensate(ctx context.Context, m define.Msg) error {
+	if p.PolyAPI == nil {
+		return nil
+	}
+	resp, err := p.PolyAPI.DeleteAPP(ctx, m.AppID)
+	if err = client.CheckDelResp(resp, err); err != nil {
+		logger.Logger.Errorf("compensate polyapi: %s", err)
+		return err
+	}
+	return nil
+}
+
 // Bit Bit
 func (*PolyExecutor) Bit() int {
 	return define.BitPolyAPI
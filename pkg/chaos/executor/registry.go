@@ -0,0 +1,337 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/handle"
+	client2 "github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/cabin/tailormade/client"
+)
+
+// name of executor in the registry
+const (
+	NameForm     = "form"
+	NamePolyAPI  = "polyapi"
+	NameFlow     = "flow"
+	NameStructor = "structor"
+	NameWebhook  = "webhook"
+)
+
+// Factory build the executor of conf, the bit, dependencies and timeout
+// in conf are applied by Build.
+type Factory struct {
+	// Required keys of the endpoints
+	Required []string
+	// Optional keys of the endpoints
+	Optional []string
+	New      func(c *config.Configs, conf config.Executor) (handle.Executor, error)
+}
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		NameForm: {
+			Required: []string{"create", "assign", "find", "delete"},
+			New: func(c *config.Configs, conf config.Executor) (handle.Executor, error) {
+				return &FormExecutor{
+					Client:     client2.NewHTTPClient(internalNet(c, conf)),
+					CreateRole: conf.Endpoints["create"],
+					AssignRole: conf.Endpoints["assign"],
+					FindRole:   conf.Endpoints["find"],
+					DeleteRole: conf.Endpoints["delete"],
+				}, nil
+			},
+		},
+		NamePolyAPI: {
+			Required: []string{"init"},
+			New: func(c *config.Configs, conf config.Executor) (handle.Executor, error) {
+				sc := *c
+				sc.InternalNet = internalNet(c, conf)
+				e := &PolyExecutor{
+					Client:  client2.NewHTTPClient(sc.InternalNet),
+					PolyURL: conf.Endpoints["init"],
+				}
+				if sc.InnerHost.PolyAPI != "" {
+					if err := checkURL(sc.InnerHost.PolyAPI); err != nil {
+						return nil, err
+					}
+					e.PolyAPI = client2.NewPolyAPI(&sc)
+				}
+				return e, nil
+			},
+		},
+		NameFlow: {
+			Optional: []string{"host", "init"},
+			New: func(c *config.Configs, conf config.Executor) (handle.Executor, error) {
+				sc := *c
+				sc.InternalNet = internalNet(c, conf)
+				if host := conf.Endpoints["host"]; host != "" {
+					sc.InnerHost.FlowHost = host
+				}
+				if err := checkURL(sc.InnerHost.FlowHost); err != nil {
+					return nil, err
+				}
+				e := &FlowExecutor{
+					Client:  client2.NewHTTPClient(sc.InternalNet),
+					InitURL: conf.Endpoints["init"],
+					Flow:    client2.NewFlow(&sc),
+				}
+				if e.InitURL == "" {
+					e.InitURL = sc.InnerHost.FlowHost + flowInitPath
+				}
+				return e, nil
+			},
+		},
+		NameStructor: {
+			Optional: []string{"host", "init"},
+			New: func(c *config.Configs, conf config.Executor) (handle.Executor, error) {
+				sc := *c
+				sc.InternalNet = internalNet(c, conf)
+				if host := conf.Endpoints["host"]; host != "" {
+					sc.InnerHost.StructorHost = host
+				}
+				if err := checkURL(sc.InnerHost.StructorHost); err != nil {
+					return nil, err
+				}
+				e := &StructorExecutor{
+					Client:   client2.NewHTTPClient(sc.InternalNet),
+					InitURL:  conf.Endpoints["init"],
+					Structor: client2.NewStructor(&sc),
+				}
+				if e.InitURL == "" {
+					e.InitURL = sc.InnerHost.StructorHost + structorInitPath
+				}
+				return e, nil
+			},
+		},
+		NameWebhook: {
+			Required: []string{"url"},
+			Optional: []string{"compensate"},
+			New: func(c *config.Configs, conf config.Executor) (handle.Executor, error) {
+				return &WebhookExecutor{
+					Client:        client2.NewHTTPClient(internalNet(c, conf)),
+					URL:           conf.Endpoints["url"],
+					CompensateURL: conf.Endpoints["compensate"],
+					Secret:        conf.Webhook.Secret,
+					ExpectStatus:  conf.Webhook.ExpectStatus,
+					ExpectCode:    conf.Webhook.ExpectCode,
+					bit:           conf.Bit,
+				}, nil
+			},
+		},
+	}
+)
+
+// Register add the factory of the executor of name, it replaces the one registered.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// Build return the executors in chaos.executors, the configuration is
+// checked so that a wrong one fails on start.
+func Build(c *config.Configs) ([]handle.Executor, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	confs := c.Chaos.Executors
+	if len(confs) == 0 {
+		confs = legacyExecutors(c)
+	}
+
+	bits := make(map[int]string, len(confs))
+	executors := make([]handle.Executor, 0, len(confs))
+	for _, conf := range confs {
+		f, ok := factories[conf.Name]
+		if !ok {
+			return nil, fmt.Errorf("executor %s is not registered", conf.Name)
+		}
+		if conf.Bit <= 0 || conf.Bit&(conf.Bit-1) != 0 {
+			return nil, fmt.Errorf("executor %s: bit %d is not a single bit", conf.Name, conf.Bit)
+		}
+		if name, ok := bits[conf.Bit]; ok {
+			return nil, fmt.Errorf("executor %s: bit %d is taken by %s", conf.Name, conf.Bit, name)
+		}
+		bits[conf.Bit] = conf.Name
+		if err := checkEndpoints(f, conf); err != nil {
+			return nil, fmt.Errorf("executor %s: %w", conf.Name, err)
+		}
+
+		e, err := f.New(c, conf)
+		if err != nil {
+			return nil, fmt.Errorf("executor %s: %w", conf.Name, err)
+		}
+		executors = append(executors, wrap(e, conf))
+	}
+
+	for _, conf := range confs {
+		for bit := 1; bit <= conf.DependsOn; bit <<= 1 {
+			if conf.DependsOn&bit != 0 && bits[bit] == "" {
+				return nil, fmt.Errorf("executor %s depends on bit %d without an executor", conf.Name, bit)
+			}
+		}
+	}
+	return executors, nil
+}
+
+// internalNet the client config with the timeout of the executor.
+func internalNet(c *config.Configs, conf config.Executor) client.Config {
+	net := c.InternalNet
+	if conf.Timeout > 0 {
+		net.Timeout = conf.Timeout
+	}
+	return net
+}
+
+// legacyExecutors the executors of initServerBits with the urls in kv.
+func legacyExecutors(c *config.Configs) []config.Executor {
+	confs := []config.Executor{
+		{
+			Name: NameForm,
+			Bit:  define.BitFormAPI,
+			Endpoints: map[string]string{
+				"create": c.KV[FormCreateRole],
+				"assign": c.KV[FormAssignRole],
+				"find":   c.KV[FormFindRole],
+				"delete": c.KV[FormDeleteRole],
+			},
+		},
+		{
+			Name: NamePolyAPI,
+			Bit:  define.BitPolyAPI,
+			Endpoints: map[string]string{
+				"init": c.KV[PolyInit],
+			},
+		},
+		{
+			Name: NameFlow,
+			Bit:  define.BitFlow,
+			Endpoints: map[string]string{
+				"init": c.KV[FlowInit],
+			},
+		},
+		{
+			Name: NameStructor,
+			Bit:  define.BitStructor,
+			Endpoints: map[string]string{
+				"init": c.KV[StructorInit],
+			},
+		},
+	}
+	ret := make([]config.Executor, 0, len(confs))
+	for _, conf := range confs {
+		if c.InitServerBits&conf.Bit != 0 {
+			ret = append(ret, conf)
+		}
+	}
+	return ret
+}
+
+func checkEndpoints(f Factory, conf config.Executor) error {
+	known := make(map[string]bool, len(f.Required)+len(f.Optional))
+	for _, key := range f.Required {
+		known[key] = true
+		if conf.Endpoints[key] == "" {
+			return fmt.Errorf("endpoint %s is required", key)
+		}
+	}
+	for _, key := range f.Optional {
+		known[key] = true
+	}
+
+	keys := make([]string, 0, len(conf.Endpoints))
+	for key := range conf.Endpoints {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			return fmt.Errorf("unknown endpoint %s", key)
+		}
+		if conf.Endpoints[key] == "" {
+			continue
+		}
+		if err := checkURL(conf.Endpoints[key]); err != nil {
+			return fmt.Errorf("endpoint %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// checkURL return an error if u is not an absolute http url,
+// the verbs of fmt in u are allowed.
+func checkURL(u string) error {
+	parsed, err := url.Parse(strings.ReplaceAll(u, "%s", "x"))
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an absolute http url", u)
+	}
+	return nil
+}
+
+// configured an executor with the bit, dependencies and timeout in config.
+type configured struct {
+	handle.Executor
+	bit       int
+	dependsOn int
+	timeout   time.Duration
+}
+
+func (e *configured) Exec(ctx context.Context, m define.Msg) error {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	return e.Executor.Exec(ctx, m)
+}
+
+func (e *configured) Bit() int {
+	return e.bit
+}
+
+func (e *configured) DependsOn() int {
+	return e.dependsOn
+}
+
+// compensable a configured executor which can undo its work.
+type compensable struct {
+	*configured
+	compensator handle.Compensator
+}
+
+func (e *compensable) Compensate(ctx context.Context, m define.Msg) error {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	return e.compensator.Compensate(ctx, m)
+}
+
+func wrap(e handle.Executor, conf config.Executor) handle.Executor {
+	ce := &configured{
+		Executor:  e,
+		bit:       conf.Bit,
+		dependsOn: conf.DependsOn,
+		timeout:   conf.Timeout * time.Second,
+	}
+	if c, ok := e.(handle.Compensator); ok {
+		return &compensable{
+			configured:  ce,
+			compensator: c,
+		}
+	}
+	return ce
+}
@@ -0,0 +1,72 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/cabin/logger"
+)
+
+// Key
+const (
+	StructorInit = "structor-init"
+)
+
+const (
+	// structorInitPath the route of the initialization on the structor host, %s is the app id
+	structorInitPath = "/api/v1/structor/%s/base/app/init"
+)
+
+// StructorExecutor StructorExecutor
+type StructorExecutor struct {
+	Client http.Client
+	// InitURL the url of the initialization, %s is the app id
+	InitURL string
+	// Structor removes the tables and the permissions of the app on compensation
+	Structor client.Structor
+}
+
+type initStructorReq struct {
+	AppID    string `json:"appID"`
+	CreateBy string `json:"createBy"`
+}
+
+type initStructorResp struct{}
+
+// Exec Exec
+func (s *StructorExecutor) Exec(ctx context.Context, m define.Msg) error {
+	initReq := &initStructorReq{
+		AppID:    m.AppID,
+		CreateBy: m.CreateBy,
+	}
+	initResp := &initStructorResp{}
+	if err := post(ctx, &s.Client, fmt.Sprintf(s.InitURL, m.AppID), initReq, initResp); err != nil {
+		logger.Logger.Errorf("init structor url: %s", fmt.Sprintf(s.InitURL, m.AppID))
+		logger.Logger.Errorf("init structor: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Compensate remove the tables and the permissions of the app
+func (s *StructorExecutor) Compensate(ctx context.Context, m define.Msg) error {
+	resp, err := s.Structor.RemoveTable(ctx, m.AppID)
+	if err = client.CheckDelResp(resp, err); err != nil {
+		logger.Logger.Errorf("compensate structor table: %s", err)
+		return err
+	}
+	resp, err = s.Structor.RemovePer(ctx, m.AppID)
+	if err = client.CheckDelResp(resp, err); err != nil {
+		logger.Logger.Errorf("compensate structor permission: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Bit Bit
+func (*StructorExecutor) Bit() int {
+	return define.BitStructor
+}
@@ -0,0 +1,176 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/cabin/logger"
+	"github.com/quanxiang-cloud/cabin/tailormade/header"
+)
+
+// header of webhook
+const (
+	HeaderSignature = "X-Chaos-Signature"
+	HeaderTimestamp = "X-Chaos-Timestamp"
+	HeaderEvent     = "X-Chaos-Event"
+)
+
+// event of webhook
+const (
+	EventInit       = "app.init"
+	EventCompensate = "app.init.compensate"
+)
+
+const maxWebhookBody = 1 << 20
+
+// WebhookExecutor post the message to an url, the app is initialized
+// by the service behind it.
+type WebhookExecutor struct {
+	Client        http.Client
+	URL           string
+	CompensateURL string
+	// Secret signs the body with HMAC-SHA256 if it is not empty
+	Secret string
+	// ExpectStatus status codes of success, any 2xx if it is empty
+	ExpectStatus []int
+	// ExpectCode the code in the json body of success, the body is not checked if it is nil
+	ExpectCode *int64
+	bit        int
+}
+
+// WebhookMsg the body posted to the webhook
+type WebhookMsg struct {
+	Event     string `json:"event"`
+	AppID     string `json:"appID"`
+	CreateBy  string `json:"createBy"`
+	TenantID  string `json:"tenantID"`
+	RequestID string `json:"requestID"`
+	Bit       int    `json:"bit"`
+	Content   int    `json:"content"`
+	Ret       int    `json:"ret"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type webhookResp struct {
+	Code int64  `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// Exec Exec
+func (w *WebhookExecutor) Exec(ctx context.Context, m define.Msg) error {
+	return w.send(ctx, w.URL, EventInit, m)
+}
+
+// Compensate Compensate
+func (w *WebhookExecutor) Compensate(ctx context.Context, m define.Msg) error {
+	if w.CompensateURL == "" {
+		return nil
+	}
+	return w.send(ctx, w.CompensateURL, EventCompensate, m)
+}
+
+// Bit Bit
+func (w *WebhookExecutor) Bit() int {
+	return w.bit
+}
+
+func (w *WebhookExecutor) send(ctx context.Context, uri, event string, m define.Msg) error {
+	now := time.Now().Unix()
+	body, err := json.Marshal(&WebhookMsg{
+		Event:     event,
+		AppID:     m.AppID,
+		CreateBy:  m.CreateBy,
+		TenantID:  headerValue(header.GetTenantID(ctx)),
+		RequestID: headerValue(header.GetRequestIDKV(ctx)),
+		Bit:       w.bit,
+		Content:   m.Content,
+		Ret:       m.Ret,
+		Timestamp: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add(header.GetRequestIDKV(ctx).Wreck())
+	req.Header.Add(header.GetTimezone(ctx).Wreck())
+	req.Header.Add(header.GetTenantID(ctx).Wreck())
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+	if w.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(w.Secret, now, body))
+	}
+
+	response, err := w.Client.Do(req)
+	if err != nil {
+		logger.Logger.Errorf("webhook url: %s", uri)
+		logger.Logger.Errorf("webhook: %s", err)
+		return err
+	}
+	defer response.Body.Close()
+
+	if !w.expectStatus(response.StatusCode) {
+		return &define.StatusError{Code: response.StatusCode}
+	}
+	if w.ExpectCode == nil {
+		return nil
+	}
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(response.Body, maxWebhookBody))
+	if err != nil {
+		return err
+	}
+	r := &webhookResp{}
+	if err := json.Unmarshal(respBody, r); err != nil {
+		return fmt.Errorf("webhook response is not json: %w", err)
+	}
+	if r.Code != *w.ExpectCode {
+		return fmt.Errorf("webhook response code %d: %s", r.Code, r.Msg)
+	}
+	return nil
+}
+
+func (w *WebhookExecutor) expectStatus(code int) bool {
+	if len(w.ExpectStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, status := range w.ExpectStatus {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign return the signature of the webhook body,
+// it is HMAC-SHA256 of timestamp, "." and body in hex with the prefix "sha256=".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func headerValue(kv header.KV) string {
+	_, value := kv.Wreck()
+	if value == "unexpected type" {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,223 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+const (
+	deadFile = "dead.json"
+)
+
+// DeadLetter a task which used up the retries, it stays until it is replayed or discarded.
+type DeadLetter struct {
+	ID string `json:"id"`
+	// Task the payload and the serialized context of the task
+	Task     data   `json:"task"`
+	Error    string `json:"error"`
+	DeadTime int64  `json:"deadTime"`
+}
+
+// deadLetters store of the dead letters
+type deadLetters interface {
+	put(dl *DeadLetter) error
+	get(id string) (*DeadLetter, error)
+	// list return the dead letters, the latest first
+	list() ([]*DeadLetter, error)
+	remove(id string) error
+	len() (int, error)
+}
+
+// newDeadLetters return the dead letters on the same backend with the queue.
+func newDeadLetters(c *config.Configs) (deadLetters, error) {
+	conf := c.Chaos.Queue
+	switch conf.Backend {
+	case "", QueueFile:
+		path := conf.Path
+		if path == "" {
+			path = c.CachePath + walSuffix
+		}
+		return newFileDeadLetters(filepath.Join(path, deadFile))
+	case QueueRedis:
+		if redis2.ClusterClient == nil {
+			return nil, fmt.Errorf("redis is not initialized for the dead letters")
+		}
+		name := conf.Stream
+		if name == "" {
+			name = defaultStream
+		}
+		return &redisDeadLetters{
+			conn: redis2.ClusterClient,
+			key:  "{" + name + "}:dead",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend %s", conf.Backend)
+	}
+}
+
+func sortDead(list []*DeadLetter) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].DeadTime != list[j].DeadTime {
+			return list[i].DeadTime > list[j].DeadTime
+		}
+		return list[i].ID > list[j].ID
+	})
+}
+
+// fileDeadLetters keeps the dead letters in memory and writes
+// a snapshot into the file on every change.
+type fileDeadLetters struct {
+	mu      sync.RWMutex
+	path    string
+	letters map[string]*DeadLetter
+}
+
+func newFileDeadLetters(path string) (*fileDeadLetters, error) {
+	fd := &fileDeadLetters{
+		path:    path,
+		letters: make(map[string]*DeadLetter),
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fd, nil
+		}
+		return nil, err
+	}
+	list := make([]*DeadLetter, 0)
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+	for _, dl := range list {
+		fd.letters[dl.ID] = dl
+	}
+	return fd, nil
+}
+
+func (fd *fileDeadLetters) put(dl *DeadLetter) error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	l := *dl
+	fd.letters[dl.ID] = &l
+	return fd.flush()
+}
+
+func (fd *fileDeadLetters) get(id string) (*DeadLetter, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
+	dl, ok := fd.letters[id]
+	if !ok {
+		return nil, nil
+	}
+	l := *dl
+	return &l, nil
+}
+
+func (fd *fileDeadLetters) list() ([]*DeadLetter, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
+	ret := make([]*DeadLetter, 0, len(fd.letters))
+	for _, dl := range fd.letters {
+		l := *dl
+		ret = append(ret, &l)
+	}
+	sortDead(ret)
+	return ret, nil
+}
+
+func (fd *fileDeadLetters) len() (int, error) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+	return len(fd.letters), nil
+}
+
+func (fd *fileDeadLetters) remove(id string) error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	if _, ok := fd.letters[id]; !ok {
+		return nil
+	}
+	delete(fd.letters, id)
+	return fd.flush()
+}
+
+func (fd *fileDeadLetters) flush() error {
+	list := make([]*DeadLetter, 0, len(fd.letters))
+	for _, dl := range fd.letters {
+		list = append(list, dl)
+	}
+	body, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return writeFile(fd.path, body)
+}
+
+// redisDeadLetters keeps the dead letters in a hash by id.
+type redisDeadLetters struct {
+	conn redis.UniversalClient
+	key  string
+}
+
+func (rd *redisDeadLetters) put(dl *DeadLetter) error {
+	body, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	return rd.conn.HSet(context.Background(), rd.key, dl.ID, body).Err()
+}
+
+func (rd *redisDeadLetters) get(id string) (*DeadLetter, error) {
+	body, err := rd.conn.HGet(context.Background(), rd.key, id).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	dl := &DeadLetter{}
+	if err := json.Unmarshal(body, dl); err != nil {
+		return nil, err
+	}
+	return dl, nil
+}
+
+func (rd *redisDeadLetters) len() (int, error) {
+	n, err := rd.conn.HLen(context.Background(), rd.key).Result()
+	return int(n), err
+}
+
+func (rd *redisDeadLetters) list() ([]*DeadLetter, error) {
+	values, err := rd.conn.HVals(context.Background(), rd.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*DeadLetter, 0, len(values))
+	for _, value := range values {
+		dl := &DeadLetter{}
+		if err := json.Unmarshal([]byte(value), dl); err != nil {
+			continue
+		}
+		ret = append(ret, dl)
+	}
+	sortDead(ret)
+	return ret, nil
+}
+
+func (rd *redisDeadLetters) remove(id string) error {
+	return rd.conn.HDel(context.Background(), rd.key, id).Err()
+}
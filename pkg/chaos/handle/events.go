@@ -0,0 +1,134 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+const eventBuffer = 16
+
+// eventBus delivers the events of the tasks to the subscribers of the app.
+// Events are dropped for the subscribers which do not keep up.
+type eventBus interface {
+	publish(e define.Event)
+	// subscribe return the events of the app until cancel is called
+	subscribe(appID string) (events <-chan define.Event, cancel func())
+}
+
+// newEventBus return the bus on the same backend with the queue,
+// the instances sharing a redis queue share the events.
+func newEventBus(c *config.Configs) eventBus {
+	conf := c.Chaos.Queue
+	if conf.Backend == QueueRedis && redis2.ClusterClient != nil {
+		name := conf.Stream
+		if name == "" {
+			name = defaultStream
+		}
+		return &redisEventBus{
+			conn:   redis2.ClusterClient,
+			prefix: name + ":events:",
+		}
+	}
+	return &localEventBus{
+		subscribers: make(map[string]map[chan define.Event]struct{}),
+	}
+}
+
+type localEventBus struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan define.Event]struct{}
+}
+
+func (lb *localEventBus) publish(e define.Event) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	for ch := range lb.subscribers[e.AppID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (lb *localEventBus) subscribe(appID string) (<-chan define.Event, func()) {
+	ch := make(chan define.Event, eventBuffer)
+	lb.mu.Lock()
+	if lb.subscribers[appID] == nil {
+		lb.subscribers[appID] = make(map[chan define.Event]struct{})
+	}
+	lb.subscribers[appID][ch] = struct{}{}
+	lb.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			lb.mu.Lock()
+			defer lb.mu.Unlock()
+			delete(lb.subscribers[appID], ch)
+			if len(lb.subscribers[appID]) == 0 {
+				delete(lb.subscribers, appID)
+			}
+			close(ch)
+		})
+	}
+}
+
+// redisEventBus publishes the events on the channel of the app.
+type redisEventBus struct {
+	conn   redis.UniversalClient
+	prefix string
+}
+
+func (rb *redisEventBus) publish(e define.Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	rb.conn.Publish(context.Background(), rb.prefix+e.AppID, body)
+}
+
+func (rb *redisEventBus) subscribe(appID string) (<-chan define.Event, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pubsub := rb.conn.Subscribe(ctx, rb.prefix+appID)
+	ch := make(chan define.Event, eventBuffer)
+	go func() {
+		defer close(ch)
+		for msg := range pubsub.Channel() {
+			e := define.Event{}
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				continue
+			}
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			pubsub.Close()
+			cancel()
+		})
+	}
+}
+
+type progressKey struct{}
+
+// withProgress let the executors of buildExec report to f when they finish.
+func withProgress(ctx context.Context, f func(bit int, err error)) context.Context {
+	return context.WithValue(ctx, progressKey{}, f)
+}
+
+func reportProgress(ctx context.Context, bit int, err error) {
+	if f, ok := ctx.Value(progressKey{}).(func(bit int, err error)); ok {
+		f(bit, err)
+	}
+}
@@ -0,0 +1,261 @@
+package handle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/metrics"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// errSkipped the executor did not run because an executor it depends on failed
+var errSkipped = errors.New("skipped")
+
+// execError the error of the executor on bit
+type execError struct {
+	bit int
+	err error
+}
+
+func (e *execError) Error() string {
+	return fmt.Sprintf("executor %d: %s", e.bit, e.err.Error())
+}
+
+func (e *execError) Unwrap() error {
+	return e.err
+}
+
+// execErrors the errors of the executors failed in a run
+type execErrors []*execError
+
+func (es execErrors) Error() string {
+	msg := make([]string, 0, len(es))
+	for _, e := range es {
+		msg = append(msg, e.Error())
+	}
+	return strings.Join(msg, "; ")
+}
+
+// failedExecutors return the errors of the executors in err,
+// the executors skipped did not fail.
+func failedExecutors(err error) []*execError {
+	var es execErrors
+	if errors.As(err, &es) {
+		ret := make([]*execError, 0, len(es))
+		for _, e := range es {
+			if !errors.Is(e.err, errSkipped) {
+				ret = append(ret, e)
+			}
+		}
+		return ret
+	}
+	var e *execError
+	if errors.As(err, &e) {
+		return []*execError{e}
+	}
+	return []*execError{{bit: define.BitAways, err: err}}
+}
+
+type node struct {
+	executor  Executor
+	bit       int
+	dependsOn int
+}
+
+// buildExec run the executors required by msg.Content and not done in msg.Ret.
+// An executor starts when the executors it depends on succeed, the executors
+// ready at the same time run in parallel. A failure only blocks the executors
+// depending on it, the others still run.
+func buildExec(executors []Executor) handler {
+	nodes := make([]node, 0, len(executors))
+	for _, e := range executors {
+		n := node{
+			executor: e,
+			bit:      e.Bit(),
+		}
+		if d, ok := e.(Dependent); ok {
+			n.dependsOn = d.DependsOn()
+		}
+		nodes = append(nodes, n)
+	}
+
+	return func(ctx context.Context, msg define.Msg) (int, error) {
+		// dependencies which are not required are satisfied
+		done := make([]bool, len(nodes))
+		for i, n := range nodes {
+			if n.bit != define.BitAways && (msg.Content&n.bit != n.bit || msg.Ret&n.bit != 0) {
+				done[i] = true
+			}
+		}
+
+		var failed execErrors
+		for {
+			ready := make([]int, 0)
+			for i, n := range nodes {
+				if done[i] {
+					continue
+				}
+				if deps := n.dependsOn & msg.Content; msg.Ret&deps == deps {
+					ready = append(ready, i)
+				}
+			}
+			if len(ready) == 0 {
+				break
+			}
+
+			errs := make([]error, len(ready))
+			var wg sync.WaitGroup
+			for k, i := range ready {
+				wg.Add(1)
+				go func(k int, e Executor, msg define.Msg) {
+					defer wg.Done()
+					ctx, span := tracing.Start(ctx, "chaos.executor",
+						attribute.Int("chaos.executor.bit", e.Bit()),
+						attribute.String("chaos.app_id", msg.AppID),
+					)
+					start := time.Now()
+					errs[k] = e.Exec(ctx, msg)
+					metrics.ObserveExecutor(e.Bit(), errs[k], start)
+					tracing.End(span, errs[k])
+				}(k, nodes[i].executor, msg)
+			}
+			wg.Wait()
+
+			for k, i := range ready {
+				done[i] = true
+				reportProgress(ctx, nodes[i].bit, errs[k])
+				if errs[k] != nil {
+					failed = append(failed, &execError{bit: nodes[i].bit, err: errs[k]})
+					continue
+				}
+				msg.Ret |= nodes[i].bit
+			}
+		}
+
+		// the executors left depend on a failed one, directly or not
+		var blocked int
+		for _, e := range failed {
+			blocked |= e.bit
+		}
+		for skipped := true; skipped; {
+			skipped = false
+			for i, n := range nodes {
+				if done[i] || n.dependsOn&blocked == 0 {
+					continue
+				}
+				done[i] = true
+				skipped = true
+				blocked |= n.bit
+				failed = append(failed, &execError{
+					bit: n.bit,
+					err: fmt.Errorf("%w, dependencies %d failed", errSkipped, n.dependsOn&blocked&^n.bit),
+				})
+			}
+		}
+		// or wait for dependencies without executors, or in a cycle
+		for i, n := range nodes {
+			if !done[i] {
+				failed = append(failed, &execError{
+					bit: n.bit,
+					err: fmt.Errorf("dependencies %d are not satisfied", n.dependsOn&msg.Content&^msg.Ret),
+				})
+			}
+		}
+		if len(failed) != 0 {
+			return msg.Ret, failed
+		}
+		return msg.Ret, nil
+	}
+}
+
+type compensator func(ctx context.Context, msg define.Msg, bits int) (int, error)
+
+// buildCompensate undo the executors in bits in the reverse order of running,
+// the dependents before the executors they depend on. It goes on after a failure
+// and return the bits compensated.
+func buildCompensate(executors []Executor) compensator {
+	nodes := make([]node, 0, len(executors))
+	for _, e := range executors {
+		n := node{
+			executor: e,
+			bit:      e.Bit(),
+		}
+		if d, ok := e.(Dependent); ok {
+			n.dependsOn = d.DependsOn()
+		}
+		nodes = append(nodes, n)
+	}
+	order := reverseOrder(nodes)
+
+	return func(ctx context.Context, msg define.Msg, bits int) (int, error) {
+		var (
+			compensated int
+			failed      execErrors
+		)
+		for _, i := range order {
+			n := nodes[i]
+			c, ok := n.executor.(Compensator)
+			if !ok || n.bit == define.BitAways || bits&n.bit != n.bit {
+				continue
+			}
+			if err := c.Compensate(ctx, msg); err != nil {
+				failed = append(failed, &execError{bit: n.bit, err: err})
+				continue
+			}
+			compensated |= n.bit
+		}
+		if len(failed) != 0 {
+			return compensated, failed
+		}
+		return compensated, nil
+	}
+}
+
+// reverseOrder return the indexes of nodes, a node is before the nodes it depends on.
+func reverseOrder(nodes []node) []int {
+	order := make([]int, 0, len(nodes))
+	placed := make([]bool, len(nodes))
+	all := allBits(nodes)
+	var ran int
+	for len(order) < len(nodes) {
+		progress := false
+		for i, n := range nodes {
+			if placed[i] || n.dependsOn&ran != n.dependsOn&all {
+				continue
+			}
+			placed[i] = true
+			progress = true
+			order = append(order, i)
+			ran |= n.bit
+		}
+		if progress {
+			continue
+		}
+		// a cycle, the rest keep the order of registration
+		for i := range nodes {
+			if !placed[i] {
+				placed[i] = true
+				order = append(order, i)
+			}
+		}
+	}
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+func allBits(nodes []node) int {
+	var bits int
+	for _, n := range nodes {
+		bits |= n.bit
+	}
+	return bits
+}
@@ -0,0 +1,207 @@
+package handle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+)
+
+type fakeExecutor struct {
+	bit       int
+	dependsOn int
+	err       error
+
+	mu   *sync.Mutex
+	runs *[]int
+	undo *[]int
+}
+
+func (e *fakeExecutor) Exec(ctx context.Context, msg define.Msg) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	*e.runs = append(*e.runs, e.bit)
+	return e.err
+}
+
+func (e *fakeExecutor) Bit() int { return e.bit }
+
+func (e *fakeExecutor) DependsOn() int { return e.dependsOn }
+
+func (e *fakeExecutor) Compensate(ctx context.Context, msg define.Msg) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	*e.undo = append(*e.undo, e.bit)
+	return nil
+}
+
+type fakeGraph struct {
+	mu        sync.Mutex
+	runs      []int
+	undo      []int
+	executors []Executor
+}
+
+func (g *fakeGraph) add(bit, dependsOn int, err error) {
+	g.executors = append(g.executors, &fakeExecutor{
+		bit:       bit,
+		dependsOn: dependsOn,
+		err:       err,
+		mu:        &g.mu,
+		runs:      &g.runs,
+		undo:      &g.undo,
+	})
+}
+
+func (g *fakeGraph) ran(bit int) bool {
+	for _, b := range g.runs {
+		if b == bit {
+			return true
+		}
+	}
+	return false
+}
+
+// index of the bit in the runs, -1 if it did not run
+func (g *fakeGraph) index(bit int) int {
+	for i, b := range g.runs {
+		if b == bit {
+			return i
+		}
+	}
+	return -1
+}
+
+func errorOf(t *testing.T, err error, bit int) *execError {
+	t.Helper()
+	var es execErrors
+	if !errors.As(err, &es) {
+		t.Fatalf("err = %v, want the errors of the executors", err)
+	}
+	for _, e := range es {
+		if e.bit == bit {
+			return e
+		}
+	}
+	return nil
+}
+
+func TestGraphOrder(t *testing.T) {
+	g := &fakeGraph{}
+	g.add(1, 0, nil)
+	g.add(2, 1, nil)
+	g.add(4, 1, nil)
+	g.add(8, 2|4, nil)
+
+	ret, err := buildExec(g.executors)(context.Background(), define.Msg{Content: 15})
+	if err != nil || ret != 15 {
+		t.Fatalf("ret = %d, err = %v", ret, err)
+	}
+	if g.index(1) != 0 || g.index(8) != 3 {
+		t.Fatalf("runs = %v, want 1 first and 8 last", g.runs)
+	}
+}
+
+func TestGraphSkipDone(t *testing.T) {
+	g := &fakeGraph{}
+	g.add(1, 0, nil)
+	g.add(2, 1, nil)
+	g.add(4, 0, nil)
+
+	// 1 is done and 4 is not required
+	ret, err := buildExec(g.executors)(context.Background(), define.Msg{Content: 3, Ret: 1})
+	if err != nil || ret != 3 {
+		t.Fatalf("ret = %d, err = %v", ret, err)
+	}
+	if len(g.runs) != 1 || g.runs[0] != 2 {
+		t.Fatalf("runs = %v, want 2 only", g.runs)
+	}
+}
+
+func TestGraphFailure(t *testing.T) {
+	g := &fakeGraph{}
+	failure := errors.New("failure")
+	g.add(1, 0, failure)
+	g.add(2, 1, nil)
+	g.add(4, 2, nil)
+	g.add(8, 0, nil)
+
+	ret, err := buildExec(g.executors)(context.Background(), define.Msg{Content: 15})
+	if ret != 8 {
+		t.Fatalf("ret = %d, want the independent executor done", ret)
+	}
+	if g.ran(2) || g.ran(4) {
+		t.Fatalf("runs = %v, the dependents of the failure ran", g.runs)
+	}
+
+	// the dependents are reported as skipped but they did not fail
+	for _, bit := range []int{2, 4} {
+		e := errorOf(t, err, bit)
+		if e == nil || !errors.Is(e.err, errSkipped) {
+			t.Fatalf("executor %d is not reported as skipped: %v", bit, err)
+		}
+	}
+	failed := failedExecutors(err)
+	if len(failed) != 1 || failed[0].bit != 1 || !errors.Is(failed[0], failure) {
+		t.Fatalf("failed = %v, want executor 1 only", failed)
+	}
+}
+
+func TestGraphMissingDependency(t *testing.T) {
+	g := &fakeGraph{}
+	g.add(1, 0, nil)
+	g.add(2, 16, nil)
+
+	ret, err := buildExec(g.executors)(context.Background(), define.Msg{Content: 1 | 2 | 16})
+	if ret != 1 {
+		t.Fatalf("ret = %d, want 1", ret)
+	}
+	e := errorOf(t, err, 2)
+	if e == nil || errors.Is(e.err, errSkipped) || !strings.Contains(e.Error(), "not satisfied") {
+		t.Fatalf("err = %v, want executor 2 not satisfied", err)
+	}
+
+	// a dependency which is not required is ignored
+	g.runs = nil
+	ret, err = buildExec(g.executors)(context.Background(), define.Msg{Content: 1 | 2})
+	if err != nil || ret != 3 {
+		t.Fatalf("ret = %d, err = %v", ret, err)
+	}
+}
+
+func TestGraphCycle(t *testing.T) {
+	g := &fakeGraph{}
+	g.add(1, 0, nil)
+	g.add(2, 4, nil)
+	g.add(4, 2, nil)
+
+	ret, err := buildExec(g.executors)(context.Background(), define.Msg{Content: 7})
+	if ret != 1 {
+		t.Fatalf("ret = %d, want 1", ret)
+	}
+	if g.ran(2) || g.ran(4) {
+		t.Fatalf("runs = %v, the executors in the cycle ran", g.runs)
+	}
+	if len(failedExecutors(err)) != 2 {
+		t.Fatalf("err = %v, want both executors in the cycle failed", err)
+	}
+}
+
+func TestCompensateOrder(t *testing.T) {
+	g := &fakeGraph{}
+	g.add(1, 0, nil)
+	g.add(2, 1, nil)
+	g.add(4, 2, nil)
+	g.add(8, 0, nil)
+
+	compensated, err := buildCompensate(g.executors)(context.Background(), define.Msg{}, 1|2|4)
+	if err != nil || compensated != 7 {
+		t.Fatalf("compensated = %d, err = %v", compensated, err)
+	}
+	if len(g.undo) != 3 || g.undo[0] != 4 || g.undo[1] != 2 || g.undo[2] != 1 {
+		t.Fatalf("undo = %v, want the dependents first", g.undo)
+	}
+}
@@ -2,55 +2,89 @@ package handle
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/quanxiang-cloud/appcenter/pkg/broker"
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	"github.com/quanxiang-cloud/appcenter/pkg/metrics"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 	"github.com/quanxiang-cloud/cabin/logger"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type data struct {
+	ID           string          `json:"id"`
 	Msg          define.Msg      `json:"msg"`
 	SerializeCTX serializeCTX    `json:"ctx"`
 	CTX          context.Context `json:"-"`
+	Ret          int             `json:"ret"` // Msg.Ret is not serialized
 	Retry        int             `json:"retry"`
+	Failures     map[int]int     `json:"failures,omitempty"` // failures by the bit of executor
 	Time         int64           `json:"time"`
+	// State the values kept by the executors across the attempts
+	State map[string]string `json:"state,omitempty"`
+	// Dead the task failed and waits to be kept as a dead letter with DeadError
+	Dead      bool   `json:"dead,omitempty"`
+	DeadError string `json:"deadError,omitempty"`
 }
 
-type handler func(context.Context, define.Msg) (int, error)
+const (
+	// pollInterval the longest wait of getTasks
+	pollInterval = 2 * time.Minute
 
-func buildExec(executors []Executor) handler {
-	return func(ctx context.Context, msg define.Msg) (int, error) {
-		for _, e := range executors {
-			if (msg.Content&e.Bit()) == e.Bit() && (msg.Ret&e.Bit() == 0) {
-				if err := e.Exec(ctx, msg); err != nil {
-					return msg.Ret, err
-				}
-				msg.Ret += e.Bit()
-			}
-		}
-		return msg.Ret, nil
-	}
-}
+	defaultDrainTimeout = 30
+
+	// buryDelay the wait before a failed task is kept as a dead letter again
+	buryDelay = time.Minute
+)
+
+var (
+	// ErrDeadLetterNotFound the dead letter does not exist
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	// ErrAppIDRequired the message has no app id
+	ErrAppIDRequired = errors.New("appID is required")
+	// ErrUnknownBits the content of the message has bits of no executor
+	ErrUnknownBits = errors.New("unknown bits")
+)
+
+type handler func(context.Context, define.Msg) (int, error)
 
 // TaskHandler TaskHandler
 type TaskHandler struct {
-	Stopped bool
-	Config  *config.Configs
-
-	task         chan data
-	taskQueue    *taskQueue
-	workload     int
-	maximumRetry int
-	waitTime     int
-	defaultBit   int
-	firstInit    bool
+	Config *config.Configs
+
+	mu      sync.RWMutex
+	stopped bool
+	// deadMu serializes the replay and the discard of the dead letters
+	deadMu sync.Mutex
+	done   chan struct{}
+	// workers the goroutines of run and getTasks
+	workers      sync.WaitGroup
+	drainTimeout time.Duration
+
+	task          chan data
+	notify        chan struct{}
+	taskQueue     queue
+	registry      Registry
+	dead          deadLetters
+	events        eventBus
+	workload      int
+	retryPolicy   *retryPolicy
+	retryPolicies map[int]*retryPolicy
+	defaultBit    int
+	// knownBits bits of the task executors
+	knownBits int
+	firstInit bool
+	// putMu serializes the lookup of the in-flight tasks and the put
+	putMu sync.Mutex
 
 	initHandler    InitExecutor
 	taskHandler    handler
+	compensate     compensator
 	successHandler handler
 	failureHandler handler
 
@@ -60,55 +94,133 @@ type TaskHandler struct {
 
 // New New
 func New(c *config.Configs, broker *broker.Broker, log logger.AdaptedLogger) (*TaskHandler, error) {
-	taskQueue, init, err := newTaskQueue(c.CachePath)
+	taskQueue, init, err := newQueue(c)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := newRegistry(c)
+	if err != nil {
+		return nil, err
+	}
+	dead, err := newDeadLetters(c)
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := migrateLegacy(c.CachePath, taskQueue)
 	if err != nil {
 		return nil, err
 	}
 
+	drainTimeout := c.Chaos.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = defaultDrainTimeout
+	}
+	retryPolicy, retryPolicies := newRetryPolicies(c)
 	handler := &TaskHandler{
-		Config: c,
-		task:   make(chan data, c.WorkLoad*4), Stopped: false,
-		taskQueue:    taskQueue,
-		workload:     c.WorkLoad,
-		maximumRetry: c.MaximumRetry,
-		waitTime:     c.WaitTime,
-		defaultBit:   c.InitServerBits,
-		firstInit:    init,
-		broker:       broker,
-		log:          log,
+		Config:        c,
+		done:          make(chan struct{}),
+		drainTimeout:  drainTimeout * time.Second,
+		task:          make(chan data, c.WorkLoad*4),
+		notify:        make(chan struct{}, 1),
+		taskQueue:     taskQueue,
+		registry:      registry,
+		dead:          dead,
+		events:        newEventBus(c),
+		workload:      c.WorkLoad,
+		retryPolicy:   retryPolicy,
+		retryPolicies: retryPolicies,
+		defaultBit:    c.InitServerBits,
+		firstInit:     init && !migrated,
+		broker:        broker,
+		log:           log,
 	}
+	metrics.SetQueueStats(handler.queueStats)
 
 	return handler, nil
 }
 
-// Put Put
-func (ih *TaskHandler) Put(ctx context.Context, msg define.Msg) error {
-	if !ih.Stopped {
-		if msg.Content == 0 {
-			msg.Content = ih.defaultBit
-		}
+func (ih *TaskHandler) queueStats() metrics.QueueStats {
+	stats := metrics.QueueStats{
+		Memory: len(ih.task),
+	}
+	var err error
+	if stats.Durable, err = ih.taskQueue.len(); err != nil {
+		ih.log.Errorf("[metrics] failed to get the length of the queue: %s", err.Error())
+	}
+	if stats.DeadLetters, err = ih.dead.len(); err != nil {
+		ih.log.Errorf("[metrics] failed to get the number of dead letters: %s", err.Error())
+	}
+	return stats
+}
 
-		d := data{
-			Msg:          msg,
-			SerializeCTX: marshalCTXHeader(ctx),
-			CTX:          ctx,
-			Retry:        0,
-			Time:         time.Now().Unix(),
-		}
+// Validate return an error if the message can not be a task.
+func (ih *TaskHandler) Validate(msg define.Msg) error {
+	if msg.AppID == "" {
+		return ErrAppIDRequired
+	}
+	if unknown := msg.Content &^ ih.knownBits; ih.knownBits != 0 && unknown != 0 {
+		return fmt.Errorf("%w %d in content %d", ErrUnknownBits, unknown, msg.Content)
+	}
+	return nil
+}
 
-		if len(ih.task) < cap(ih.task) {
-			ih.log.Debug("put msg into task")
-			ih.task <- d
-			return nil
-		}
+// Put put the message into the queue and return the id of the task.
+// A message of the app with the same content as an in-flight task
+// is coalesced into that task, its id is returned with coalesced true.
+func (ih *TaskHandler) Put(ctx context.Context, msg define.Msg) (id string, coalesced bool, err error) {
+	// a stop waits for the puts in progress
+	ih.mu.RLock()
+	defer ih.mu.RUnlock()
+	if ih.stopped {
+		return "", false, fmt.Errorf("handler is stopping")
+	}
+	if msg.Content == 0 {
+		msg.Content = ih.defaultBit
+	}
+	if err := ih.Validate(msg); err != nil {
+		return "", false, err
+	}
 
-		ih.log.Debug("put msg into disk")
-		if err := ih.taskQueue.put(d); err != nil {
-			return err
+	// the app id and the content are the idempotency key,
+	// instances sharing a redis queue may still race between the lookup and the put
+	ih.putMu.Lock()
+	defer ih.putMu.Unlock()
+	if id, err := ih.inFlight(msg); err != nil || id != "" {
+		return id, id != "", err
+	}
+
+	d := data{
+		Msg:          msg,
+		SerializeCTX: marshalCTXHeader(ctx),
+		CTX:          ctx,
+		Retry:        0,
+		Time:         time.Now().Unix(),
+	}
+
+	// the task is durable until the executors finish it,
+	// it reaches the workers through the queue so that
+	// every instance sharing the queue gets it once
+	if err := ih.taskQueue.put(&d); err != nil {
+		return "", false, err
+	}
+	ih.log.Debug("put msg into queue")
+	ih.record(&d, StatePending, nil)
+	ih.wake()
+	return d.ID, false, nil
+}
+
+// inFlight return the id of the unfinished task of the app with the same content.
+func (ih *TaskHandler) inFlight(msg define.Msg) (string, error) {
+	list, err := ih.registry.ListByApp(msg.AppID)
+	if err != nil {
+		return "", err
+	}
+	for _, status := range list {
+		if status.Content == msg.Content && !IsFinished(status.State) {
+			return status.ID, nil
 		}
-		return nil
 	}
-	return fmt.Errorf("handler is stopping")
+	return "", nil
 }
 
 // Run Run
@@ -136,6 +248,7 @@ func (ih *TaskHandler) Run() error {
 		}
 	}
 
+	ih.workers.Add(ih.workload + 1)
 	go ih.getTasks()
 
 	for i := 0; i < ih.workload; i++ {
@@ -148,65 +261,413 @@ func (ih *TaskHandler) Run() error {
 	return nil
 }
 
+// wake let getTasks pop the queue without waiting.
+func (ih *TaskHandler) wake() {
+	select {
+	case ih.notify <- struct{}{}:
+	default:
+	}
+}
+
 func (ih *TaskHandler) getTasks() {
+	defer ih.workers.Done()
 	for {
-		d, err := ih.taskQueue.pop(ih.workload * 8)
+		select {
+		case <-ih.done:
+			return
+		default:
+		}
+
+		n := cap(ih.task) - len(ih.task)
+		if n == 0 {
+			n = 1
+		}
+		d, err := ih.taskQueue.pop(n)
 		if err != nil {
 			ih.log.Infof(err.Error())
 		}
 		if len(d) == 0 {
-			time.Sleep(2 * time.Minute)
+			if b, ok := ih.taskQueue.(blocker); ok && b.blocks() && err == nil {
+				continue
+			}
+			ih.wait()
 			continue
 		}
 
-		for _, one := range d {
-			task := &data{}
-			if err := json.Unmarshal(one, task); err != nil {
-				ih.log.Errorf(err.Error())
-				continue
-			}
+		for i, task := range d {
 			task.CTX = unmarshalCTXHeader(task.SerializeCTX)
-			ih.task <- *task
+			task.Msg.Ret = task.Ret
+			select {
+			case ih.task <- task:
+			case <-ih.done:
+				// give the lease of the tasks not sent back
+				for _, left := range d[i:] {
+					if err := ih.taskQueue.nack(left); err != nil {
+						ih.log.Errorf(err.Error())
+					}
+				}
+				return
+			}
+		}
+	}
+}
+
+// wait block until the earliest delayed task is due or the queue is woken up.
+// Tasks of the other instances sharing the queue are found by polling.
+func (ih *TaskHandler) wait() {
+	d := pollInterval
+	next, ok, err := ih.taskQueue.next()
+	if err != nil {
+		ih.log.Infof(err.Error())
+	}
+	if ok {
+		if until := time.Until(time.Unix(next, 0)); until < d {
+			d = until
+		}
+		if d < time.Second {
+			d = time.Second
 		}
 	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ih.notify:
+	case <-timer.C:
+	case <-ih.done:
+	}
+}
+
+func (ih *TaskHandler) policy(bit int) *retryPolicy {
+	if p, ok := ih.retryPolicies[bit]; ok {
+		return p
+	}
+	return ih.retryPolicy
 }
 
 func (ih *TaskHandler) run() {
+	defer ih.workers.Done()
 	for {
-		data := <-ih.task
-		ih.log.Debugf("do task [%v]", data)
-
-		if data.Time <= time.Now().Unix() {
-			ret, err := ih.taskHandler(data.CTX, data.Msg)
-			data.Msg.Ret = ret
-			if err != nil {
-				ih.log.Errorf("[TaskHandler] failed to init-server: %s", err.Error())
-
-				data.Retry++
-				if data.Retry < ih.maximumRetry {
-					data.Time = time.Now().Add(time.Duration(data.Retry*ih.waitTime) * time.Minute).Unix()
-					if err := ih.taskQueue.put(data); err != nil {
-						ih.log.Errorf(err.Error())
-					}
-				} else {
-					if _, err := ih.failureHandler(data.CTX, data.Msg); err != nil {
-						ih.log.Errorf("[failureHandler] failed to do: %s", err.Error())
-					}
-				}
-				continue
-			}
+		select {
+		case <-ih.done:
+			return
+		case data := <-ih.task:
+			ih.do(data)
+		}
+	}
+}
 
-			if _, err := ih.successHandler(data.CTX, data.Msg); err != nil {
-				ih.log.Errorf("[resultHandler] failed to do: %s", err.Error())
+func (ih *TaskHandler) do(data data) {
+	ih.log.Debugf("do task [%v]", data)
+	if data.Dead {
+		ih.bury(data)
+		return
+	}
+	ih.revived(data.ID)
+
+	ih.record(&data, StateRunning, nil)
+	attempt := &Attempt{Time: time.Now().Unix()}
+	before := data.Msg.Ret
+	ctx, span := tracing.Start(data.CTX, "chaos.task",
+		attribute.String("chaos.task_id", data.ID),
+		attribute.String("chaos.app_id", data.Msg.AppID),
+		attribute.Int("chaos.retry", data.Retry),
+	)
+	ctx = withProgress(ctx, func(bit int, err error) {
+		e := define.Event{
+			Type:   define.EventExecutor,
+			TaskID: data.ID,
+			AppID:  data.Msg.AppID,
+			Bit:    bit,
+			Time:   time.Now().Unix(),
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		ih.events.publish(e)
+	})
+	ctx, state := withState(ctx, data.State)
+	ret, err := ih.taskHandler(ctx, data.Msg)
+	tracing.End(span, err)
+	data.State = state.snapshot()
+	data.Msg.Ret = ret
+	data.Ret = ret
+	attempt.Succeeded = ret &^ before
+	if err != nil {
+		ih.log.Errorf("[TaskHandler] failed to init-server: %s", err.Error())
+		attempt.Error = err.Error()
+
+		// the task is retried when every failed executor can be retried,
+		// after the longest of their delays
+		data.Retry++
+		if data.Failures == nil {
+			data.Failures = make(map[int]int)
+		}
+		retry := true
+		var delay time.Duration
+		for _, e := range failedExecutors(err) {
+			attempt.Failed |= e.bit
+			data.Failures[e.bit]++
+			policy := ih.policy(e.bit)
+			failures := data.Failures[e.bit]
+			if !policy.canRetry(failures, e.err) {
+				retry = false
+			}
+			if d := policy.delay(failures); d > delay {
+				delay = d
+			}
+		}
+		if retry {
+			for _, e := range failedExecutors(err) {
+				metrics.IncRetry(e.bit)
 			}
-		} else {
-			ih.log.Debug("put msg into disk")
-			if err := ih.taskQueue.put(data); err != nil {
+			data.Time = time.Now().Add(delay).Unix()
+			if err := ih.taskQueue.nack(data); err != nil {
 				ih.log.Errorf(err.Error())
 			}
+			ih.record(&data, StateRetrying, attempt)
+			ih.wake()
+			return
 		}
+		ih.abandon(&data, attempt)
+		if _, err := ih.failureHandler(data.CTX, data.Msg); err != nil {
+			ih.log.Errorf("[failureHandler] failed to do: %s", err.Error())
+		}
+		ih.record(&data, StateFailed, attempt)
+
+		data.DeadError = attempt.Error
+		ih.bury(data)
+		return
+	}
+	if _, err := ih.successHandler(data.CTX, data.Msg); err != nil {
+		ih.log.Errorf("[resultHandler] failed to do: %s", err.Error())
+	}
+	ih.record(&data, StateSucceeded, attempt)
+
+	if err := ih.taskQueue.ack(data.ID); err != nil {
+		ih.log.Errorf(err.Error())
+	}
+}
+
+// bury keep the failed task as a dead letter and ack it. The task is nacked
+// with a delay if the dead letter can not be kept, only the bury is done again then.
+func (ih *TaskHandler) bury(d data) {
+	err := ih.dead.put(&DeadLetter{
+		ID:       d.ID,
+		Task:     d,
+		Error:    d.DeadError,
+		DeadTime: time.Now().Unix(),
+	})
+	if err != nil {
+		ih.log.Errorf("[deadLetter] failed to put task %s: %s", d.ID, err.Error())
+		d.Dead = true
+		d.Time = time.Now().Add(buryDelay).Unix()
+		if err := ih.taskQueue.nack(d); err != nil {
+			ih.log.Errorf(err.Error())
+		}
+		return
+	}
+	if err := ih.taskQueue.ack(d.ID); err != nil {
+		ih.log.Errorf(err.Error())
+	}
+}
+
+// revived remove the dead letter of a task running again,
+// it is left when a replay stopped between the put and the remove.
+func (ih *TaskHandler) revived(id string) {
+	dl, err := ih.dead.get(id)
+	if err != nil || dl == nil {
+		return
+	}
+	if err := ih.dead.remove(id); err != nil {
+		ih.log.Errorf("[deadLetter] failed to remove task %s: %s", id, err.Error())
+	}
+}
+
+// abandon undo the executors succeeded or failed, the failed ones
+// may leave part of their work. Only the executors the task is run for
+// are undone, the ones done before a replay of part of them are kept.
+func (ih *TaskHandler) abandon(d *data, attempt *Attempt) {
+	if ih.compensate == nil {
+		return
+	}
+	bits := d.Ret
+	for bit := range d.Failures {
+		bits |= bit
+	}
+	bits &= d.Msg.Content
+	// the executors undo the work recorded in the state of the task
+	ctx, state := withState(d.CTX, d.State)
+	compensated, err := ih.compensate(ctx, d.Msg, bits)
+	if err != nil {
+		ih.log.Errorf("[compensate] failed to undo task %s: %s", d.ID, err.Error())
+	}
+	d.State = state.snapshot()
+	attempt.Compensated = compensated
+	d.Ret &^= compensated
+	d.Msg.Ret = d.Ret
+}
+
+// record save the state of the task into the registry,
+// a failure of the registry does not stop the task.
+func (ih *TaskHandler) record(d *data, state string, attempt *Attempt) {
+	e := define.Event{
+		Type:   define.EventState,
+		TaskID: d.ID,
+		AppID:  d.Msg.AppID,
+		State:  state,
+		Ret:    d.Msg.Ret,
+		Final:  IsFinished(state),
+		Time:   time.Now().Unix(),
+	}
+	if attempt != nil {
+		e.Error = attempt.Error
+	}
+	ih.events.publish(e)
+
+	status, err := ih.registry.Get(d.ID)
+	if err != nil {
+		ih.log.Errorf("[registry] failed to get task %s: %s", d.ID, err.Error())
+		return
+	}
+	now := time.Now().Unix()
+	if status == nil {
+		status = &TaskStatus{
+			ID:         d.ID,
+			AppID:      d.Msg.AppID,
+			CreateBy:   d.Msg.CreateBy,
+			Content:    d.Msg.Content,
+			Attempts:   make([]Attempt, 0),
+			CreateTime: now,
+		}
+	}
+	status.Ret = d.Msg.Ret
+	status.State = state
+	status.Retry = d.Retry
+	status.NextRetry = 0
+	if state == StateRetrying {
+		status.NextRetry = d.Time
+	}
+	if attempt != nil {
+		status.Attempts = append(status.Attempts, *attempt)
+		status.LastError = attempt.Error
+	}
+	status.UpdateTime = now
+	if err := ih.registry.Save(status); err != nil {
+		ih.log.Errorf("[registry] failed to save task %s: %s", d.ID, err.Error())
+	}
+}
+
+// Subscribe return the events of the tasks of the app until cancel is called.
+func (ih *TaskHandler) Subscribe(appID string) (<-chan define.Event, func()) {
+	return ih.events.subscribe(appID)
+}
+
+// Tasks return a page of the status of the tasks of the app, or the tasks in the state
+// if appID is empty, and the number of the tasks.
+func (ih *TaskHandler) Tasks(appID, state string, page, limit int) ([]*TaskStatus, int64, error) {
+	if appID == "" {
+		return ih.registry.ListByState(state, page, limit)
+	}
+	list, err := ih.registry.ListByApp(appID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if state != "" {
+		ret := make([]*TaskStatus, 0, len(list))
+		for _, status := range list {
+			if status.State == state {
+				ret = append(ret, status)
+			}
+		}
+		list = ret
+	}
+	return pageOf(list, page, limit), int64(len(list)), nil
+}
+
+// DeadLetters return the dead letters, the latest first.
+func (ih *TaskHandler) DeadLetters() ([]*DeadLetter, error) {
+	return ih.dead.list()
+}
+
+// DeadLetter return the dead letter of id, nil if it does not exist.
+func (ih *TaskHandler) DeadLetter(id string) (*DeadLetter, error) {
+	return ih.dead.get(id)
+}
+
+// Replay put the dead letter back into the queue, only the executors
+// which did not succeed are run. The executors in content are run
+// if it is not zero.
+func (ih *TaskHandler) Replay(id string, content int) error {
+	ih.mu.RLock()
+	defer ih.mu.RUnlock()
+	if ih.stopped {
+		return fmt.Errorf("handler is stopping")
+	}
+	ih.deadMu.Lock()
+	defer ih.deadMu.Unlock()
+	dl, err := ih.dead.get(id)
+	if err != nil {
+		return err
+	}
+	if dl == nil {
+		return ErrDeadLetterNotFound
+	}
 
+	d := dl.Task
+	if content != 0 {
+		d.Msg.Content = content
+		d.Ret &^= content
+		if err := ih.Validate(d.Msg); err != nil {
+			return err
+		}
+	}
+	d.Msg.Ret = d.Ret
+	d.Retry = 0
+	d.Failures = nil
+	d.Dead = false
+	d.DeadError = ""
+	d.Time = time.Now().Unix()
+	if err := ih.revive(&d); err != nil {
+		return err
+	}
+	ih.record(&d, StatePending, nil)
+	ih.wake()
+	return nil
+}
+
+// revive move the task of the dead letter back into the queue. It is one transaction
+// on redis, the file queue keeps the id of the task so a replay done again
+// after a crash overwrites the task put before.
+func (ih *TaskHandler) revive(d *data) error {
+	if rq, ok := ih.taskQueue.(*redisQueue); ok {
+		if rd, ok := ih.dead.(*redisDeadLetters); ok {
+			return rq.revive(d, rd)
+		}
+	}
+	if err := ih.taskQueue.put(d); err != nil {
+		return err
+	}
+	return ih.dead.remove(d.ID)
+}
+
+// Discard remove the dead letter.
+func (ih *TaskHandler) Discard(id string) error {
+	ih.mu.RLock()
+	defer ih.mu.RUnlock()
+	if ih.stopped {
+		return fmt.Errorf("handler is stopping")
+	}
+	ih.deadMu.Lock()
+	defer ih.deadMu.Unlock()
+	dl, err := ih.dead.get(id)
+	if err != nil {
+		return err
+	}
+	if dl == nil {
+		return ErrDeadLetterNotFound
 	}
+	return ih.dead.remove(id)
 }
 
 // SetInitExecutors SetInitExecutors
@@ -217,6 +678,11 @@ func (ih *TaskHandler) SetInitExecutors(executor InitExecutor) {
 // SetTaskExecutors SetTaskExecutors
 func (ih *TaskHandler) SetTaskExecutors(executors ...Executor) {
 	ih.taskHandler = buildExec(executors)
+	ih.compensate = buildCompensate(executors)
+	ih.knownBits = 0
+	for _, e := range executors {
+		ih.knownBits |= e.Bit()
+	}
 }
 
 // SetSuccessExecutors SetSuccessExecutors
@@ -229,15 +695,72 @@ func (ih *TaskHandler) SetFailureExecutors(executors ...Executor) {
 	ih.failureHandler = buildExec(executors)
 }
 
-func (ih *TaskHandler) withCancel() {
+// Ping return an error if the handler can not accept tasks.
+func (ih *TaskHandler) Ping(ctx context.Context) error {
+	if ih.Stopped() {
+		return fmt.Errorf("handler is stopping")
+	}
+	return ih.taskQueue.ping(ctx)
+}
+
+// Stopped return true if the handler does not accept tasks.
+func (ih *TaskHandler) Stopped() bool {
+	ih.mu.RLock()
+	defer ih.mu.RUnlock()
+	return ih.stopped
+}
+
+// Stop stop accepting tasks and wait for the running executors until the timeout,
+// the tasks not started are given back to the queue.
+// The tasks still running after the timeout are run again on the next start.
+func (ih *TaskHandler) Stop(timeout time.Duration) {
+	ih.mu.Lock()
+	if ih.stopped {
+		ih.mu.Unlock()
+		return
+	}
+	ih.stopped = true
+	ih.mu.Unlock()
+	close(ih.done)
+
+	finished := make(chan struct{})
 	go func() {
-		<-ih.broker.C
-		ih.Stopped = true
+		ih.workers.Wait()
+		close(finished)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	drained := true
+	select {
+	case <-finished:
+	case <-timer.C:
+		drained = false
+		ih.log.Warnf("[TaskHandler] executors are still running after %s", timeout)
+	}
 
-		// <-time.After(3 * time.Second)
-		for len(ih.task) != 0 {
-			<-time.After(time.Second)
+drain:
+	for {
+		select {
+		case d := <-ih.task:
+			if err := ih.taskQueue.nack(d); err != nil {
+				ih.log.Errorf("[TaskHandler] failed to persist task %s: %s", d.ID, err.Error())
+			}
+		default:
+			break drain
+		}
+	}
+
+	if drained {
+		if err := ih.taskQueue.close(); err != nil {
+			ih.log.Errorf(err.Error())
 		}
+	}
+}
+
+func (ih *TaskHandler) withCancel() {
+	go func() {
+		<-ih.broker.C
+		ih.Stop(ih.drainTimeout)
 		ih.broker.Done()
 	}()
 }
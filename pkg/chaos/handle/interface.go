@@ -16,5 +16,19 @@ type Executor interface {
 	Bit() int
 }
 
+// Dependent is implemented by the executors which run after others.
+type Dependent interface {
+	// DependsOn bits of the executors which must succeed before Exec,
+	// the bits not in msg.Content are ignored.
+	DependsOn() int
+}
+
+// Compensator is implemented by the executors which can undo their work.
+type Compensator interface {
+	// Compensate is called when the task is abandoned, it must tolerate
+	// the work which is partly done or not done at all.
+	Compensate(context.Context, define.Msg) error
+}
+
 // InitExecutor InitExecutor
 type InitExecutor func(*TaskHandler) error
@@ -0,0 +1,72 @@
+package handle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+// backend of queue
+const (
+	QueueFile  = "file"
+	QueueRedis = "redis"
+)
+
+// queue keeps the tasks until they are acked.
+// A popped task is leased to the handler, other poppers do not get it
+// until it is nacked.
+type queue interface {
+	// put persist the task, the id of d is set if it is empty
+	put(d *data) error
+	// pop lease at most n tasks which are due
+	pop(n int) ([]data, error)
+	// ack remove the task from the queue
+	ack(id string) error
+	// nack persist the new state of the task and give the lease back
+	nack(d data) error
+	// next return the earliest time of the tasks which are not due,
+	// ok is false if there is no such task
+	next() (t int64, ok bool, err error)
+	// len return the number of the tasks which are not acked
+	len() (int, error)
+	// ping return an error if the queue can not persist tasks
+	ping(ctx context.Context) error
+	close() error
+}
+
+// blocker is a queue whose pop waits a while for new tasks,
+// the tasks put by the other instances are found without polling.
+type blocker interface {
+	blocks() bool
+}
+
+// newQueue return the queue of the backend in config,
+// init is true when the queue is created for the first time.
+func newQueue(c *config.Configs) (queue, bool, error) {
+	conf := c.Chaos.Queue
+	switch conf.Backend {
+	case "", QueueFile:
+		path := conf.Path
+		if path == "" {
+			path = c.CachePath + walSuffix
+		}
+		wq, init, err := newWALQueue(path, conf.SegmentSize)
+		if err != nil {
+			return nil, false, err
+		}
+		return wq, init, nil
+	case QueueRedis:
+		if redis2.ClusterClient == nil {
+			return nil, false, fmt.Errorf("redis is not initialized for the queue")
+		}
+		rq, init, err := newRedisQueue(redis2.ClusterClient, conf)
+		if err != nil {
+			return nil, false, err
+		}
+		return rq, init, nil
+	default:
+		return nil, false, fmt.Errorf("unknown queue backend %s", conf.Backend)
+	}
+}
@@ -0,0 +1,326 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+const (
+	defaultStream    = "chaos:tasks"
+	defaultGroup     = "chaos"
+	defaultClaimIdle = 300
+
+	fieldData = "data"
+
+	// readBlock the longest wait of a read for new tasks
+	readBlock = 5 * time.Second
+
+	pingTTL = 10 * time.Second
+)
+
+// promote moves the due tasks from the delayed set into the stream.
+var promote = redis.NewScript(`
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('XADD', KEYS[2], '*', 'data', item)
+	redis.call('ZREM', KEYS[1], item)
+end
+return #items
+`)
+
+// redisQueue is a queue on redis streams, the instances of chaos
+// in the same consumer group share the tasks. Tasks waiting for a retry
+// are kept in a sorted set by their time and moved into the stream when
+// they are due. Tasks pending on a dead consumer longer than claimIdle
+// are claimed by the others, the leased tasks of a live consumer are
+// claimed again by itself every claimIdle/3 so they never go idle.
+type redisQueue struct {
+	conn      redis.UniversalClient
+	stream    string
+	delayed   string
+	seq       string
+	group     string
+	consumer  string
+	claimIdle time.Duration
+
+	mu     sync.Mutex
+	leased map[string]string // task id to stream entry id
+
+	done chan struct{}
+	once sync.Once
+}
+
+func newRedisQueue(conn redis.UniversalClient, conf config.Queue) (*redisQueue, bool, error) {
+	name := conf.Stream
+	if name == "" {
+		name = defaultStream
+	}
+	group := conf.Group
+	if group == "" {
+		group = defaultGroup
+	}
+	claimIdle := conf.ClaimIdle
+	if claimIdle <= 0 {
+		claimIdle = defaultClaimIdle
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// hash tag keeps the keys in the same slot of a cluster
+	key := "{" + name + "}"
+	rq := &redisQueue{
+		conn:      conn,
+		stream:    key,
+		delayed:   key + ":delayed",
+		seq:       key + ":seq",
+		group:     group,
+		consumer:  fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		claimIdle: claimIdle * time.Second,
+		leased:    make(map[string]string),
+		done:      make(chan struct{}),
+	}
+
+	init := true
+	err = conn.XGroupCreateMkStream(context.Background(), rq.stream, rq.group, "0").Err()
+	if err != nil {
+		if !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, false, err
+		}
+		init = false
+	}
+	go rq.heartbeat()
+	return rq, init, nil
+}
+
+// heartbeat reset the idle time of the leased tasks until the queue is closed,
+// a task running longer than claimIdle is not claimed by the others.
+func (rq *redisQueue) heartbeat() {
+	ticker := time.NewTicker(rq.claimIdle / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rq.done:
+			return
+		case <-ticker.C:
+		}
+
+		rq.mu.Lock()
+		leased := make(map[string]bool, len(rq.leased))
+		for _, streamID := range rq.leased {
+			leased[streamID] = true
+		}
+		rq.mu.Unlock()
+		if len(leased) == 0 {
+			continue
+		}
+		// a failed touch is done again on the next tick
+		rq.touch(leased)
+	}
+}
+
+// touch claim the leased entries again, an entry claimed by another consumer
+// meanwhile is not taken back.
+func (rq *redisQueue) touch(leased map[string]bool) error {
+	ctx := context.Background()
+	pending, err := rq.conn.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   rq.stream,
+		Group:    rq.group,
+		Start:    "-",
+		End:      "+",
+		Count:    int64(len(leased)),
+		Consumer: rq.consumer,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(pending))
+	for _, p := range pending {
+		if leased[p.ID] {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return rq.conn.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   rq.stream,
+		Group:    rq.group,
+		Consumer: rq.consumer,
+		Messages: ids,
+	}).Err()
+}
+
+// blocks the pop waits for new tasks by itself
+func (rq *redisQueue) blocks() bool {
+	return true
+}
+
+func (rq *redisQueue) put(d *data) error {
+	ctx := context.Background()
+	if d.ID == "" {
+		seq, err := rq.conn.Incr(ctx, rq.seq).Result()
+		if err != nil {
+			return err
+		}
+		d.ID = strconv.FormatInt(seq, 10)
+	}
+	return rq.add(ctx, rq.conn, d)
+}
+
+// add the task into the delayed set or the stream by its time
+func (rq *redisQueue) add(ctx context.Context, c redis.Cmdable, d *data) error {
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	if d.Time > time.Now().Unix() {
+		return c.ZAdd(ctx, rq.delayed, &redis.Z{
+			Score:  float64(d.Time),
+			Member: string(payload),
+		}).Err()
+	}
+	return c.XAdd(ctx, &redis.XAddArgs{
+		Stream: rq.stream,
+		Values: map[string]interface{}{fieldData: string(payload)},
+	}).Err()
+}
+
+// revive put the task and remove its dead letter in one transaction,
+// the keys share the hash tag of the stream.
+func (rq *redisQueue) revive(d *data, dead *redisDeadLetters) error {
+	ctx := context.Background()
+	_, err := rq.conn.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if err := rq.add(ctx, p, d); err != nil {
+			return err
+		}
+		return p.HDel(ctx, dead.key, d.ID).Err()
+	})
+	return err
+}
+
+func (rq *redisQueue) pop(n int) ([]data, error) {
+	ctx := context.Background()
+	err := promote.Run(ctx, rq.conn, []string{rq.delayed, rq.stream}, time.Now().Unix(), n).Err()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	messages, _, err := rq.conn.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   rq.stream,
+		Group:    rq.group,
+		Consumer: rq.consumer,
+		MinIdle:  rq.claimIdle,
+		Start:    "0-0",
+		Count:    int64(n),
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	if len(messages) < n {
+		streams, err := rq.conn.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    rq.group,
+			Consumer: rq.consumer,
+			Streams:  []string{rq.stream, ">"},
+			Count:    int64(n - len(messages)),
+			Block:    readBlock,
+		}).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for _, stream := range streams {
+			messages = append(messages, stream.Messages...)
+		}
+	}
+
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	ret := make([]data, 0, len(messages))
+	for _, msg := range messages {
+		d := data{}
+		payload, _ := msg.Values[fieldData].(string)
+		if err := json.Unmarshal([]byte(payload), &d); err != nil || d.ID == "" {
+			// drop the broken entry, it never succeeds
+			rq.remove(ctx, msg.ID)
+			continue
+		}
+		rq.leased[d.ID] = msg.ID
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+func (rq *redisQueue) remove(ctx context.Context, streamID string) error {
+	_, err := rq.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.XAck(ctx, rq.stream, rq.group, streamID)
+		p.XDel(ctx, rq.stream, streamID)
+		return nil
+	})
+	return err
+}
+
+func (rq *redisQueue) ack(id string) error {
+	rq.mu.Lock()
+	streamID, ok := rq.leased[id]
+	delete(rq.leased, id)
+	rq.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return rq.remove(context.Background(), streamID)
+}
+
+func (rq *redisQueue) nack(d data) error {
+	// the new state is persisted before the old entry is removed,
+	// a crash in between runs the task twice rather than losing it
+	if err := rq.put(&d); err != nil {
+		return err
+	}
+	return rq.ack(d.ID)
+}
+
+func (rq *redisQueue) next() (int64, bool, error) {
+	items, err := rq.conn.ZRangeWithScores(context.Background(), rq.delayed, 0, 0).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(items) == 0 {
+		return 0, false, nil
+	}
+	return int64(items[0].Score), true, nil
+}
+
+func (rq *redisQueue) len() (int, error) {
+	ctx := context.Background()
+	stream, err := rq.conn.XLen(ctx, rq.stream).Result()
+	if err != nil {
+		return 0, err
+	}
+	delayed, err := rq.conn.ZCard(ctx, rq.delayed).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int(stream + delayed), nil
+}
+
+// ping write a key beside the stream, a read-only replica fails it
+func (rq *redisQueue) ping(ctx context.Context) error {
+	return rq.conn.Set(ctx, rq.stream+":ping", rq.consumer, pingTTL).Err()
+}
+
+func (rq *redisQueue) close() error {
+	rq.once.Do(func() { close(rq.done) })
+	return nil
+}
@@ -0,0 +1,166 @@
+package handle
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+)
+
+// redisRegistry keeps the status of a task in a key, which expires
+// after the retention once the task is finished. The set of the app
+// and the sorted set of the state by the create time index the ids,
+// ids of the expired keys are removed from them when they are read.
+type redisRegistry struct {
+	conn      redis.UniversalClient
+	prefix    string
+	retention time.Duration
+}
+
+func newRedisRegistry(conn redis.UniversalClient, conf config.Queue, retention time.Duration) *redisRegistry {
+	name := conf.Stream
+	if name == "" {
+		name = defaultStream
+	}
+	return &redisRegistry{
+		conn:      conn,
+		prefix:    "{" + name + "}:status:",
+		retention: retention,
+	}
+}
+
+func (rr *redisRegistry) key(id string) string {
+	return rr.prefix + id
+}
+
+func (rr *redisRegistry) appKey(appID string) string {
+	return rr.prefix + "app:" + appID
+}
+
+func (rr *redisRegistry) stateKey(state string) string {
+	return rr.prefix + "states:" + state
+}
+
+func (rr *redisRegistry) Save(status *TaskStatus) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	var expiration time.Duration
+	if IsFinished(status.State) {
+		expiration = rr.retention
+	}
+
+	ctx := context.Background()
+	_, err = rr.conn.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, rr.key(status.ID), body, expiration)
+		p.SAdd(ctx, rr.appKey(status.AppID), status.ID)
+		for _, state := range []string{StatePending, StateRunning, StateRetrying, StateSucceeded, StateFailed} {
+			if state != status.State {
+				p.ZRem(ctx, rr.stateKey(state), status.ID)
+			}
+		}
+		p.ZAdd(ctx, rr.stateKey(status.State), &redis.Z{
+			Score:  float64(status.CreateTime),
+			Member: status.ID,
+		})
+		return nil
+	})
+	return err
+}
+
+func (rr *redisRegistry) Get(id string) (*TaskStatus, error) {
+	body, err := rr.conn.Get(context.Background(), rr.key(id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	status := &TaskStatus{}
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (rr *redisRegistry) ListByApp(appID string) ([]*TaskStatus, error) {
+	set := rr.appKey(appID)
+	ids, err := rr.conn.SMembers(context.Background(), set).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret, expired, err := rr.get(ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) != 0 {
+		rr.conn.SRem(context.Background(), set, expired...)
+	}
+	sortLatest(ret)
+	return ret, nil
+}
+
+// ListByState read the page from the sorted set, the expired ids in the page
+// are removed, so a page may be short until they are all gone.
+func (rr *redisRegistry) ListByState(state string, page, limit int) ([]*TaskStatus, int64, error) {
+	ctx := context.Background()
+	set := rr.stateKey(state)
+	total, err := rr.conn.ZCard(ctx, set).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	p := page2.NewPage(page, limit, total)
+	start := int64(p.StartIndex)
+	ids, err := rr.conn.ZRevRange(ctx, set, start, start+int64(p.PageSize)-1).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	ret, expired, err := rr.get(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(expired) != 0 {
+		rr.conn.ZRem(ctx, set, expired...)
+		total -= int64(len(expired))
+	}
+	sortLatest(ret)
+	return ret, total, nil
+}
+
+// get return the status of the ids and the ids whose keys are expired
+func (rr *redisRegistry) get(ids []string) ([]*TaskStatus, []interface{}, error) {
+	ctx := context.Background()
+	ret := make([]*TaskStatus, 0, len(ids))
+	if len(ids) == 0 {
+		return ret, nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, rr.key(id))
+	}
+	values, err := rr.conn.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expired := make([]interface{}, 0)
+	for i, value := range values {
+		body, ok := value.(string)
+		if !ok {
+			expired = append(expired, ids[i])
+			continue
+		}
+		status := &TaskStatus{}
+		if err := json.Unmarshal([]byte(body), status); err != nil {
+			continue
+		}
+		ret = append(ret, status)
+	}
+	return ret, expired, nil
+}
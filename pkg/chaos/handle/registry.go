@@ -0,0 +1,313 @@
+package handle
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+	page2 "github.com/quanxiang-cloud/appcenter/pkg/page"
+	redis2 "github.com/quanxiang-cloud/appcenter/pkg/redis"
+)
+
+// state of task
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateRetrying  = "retrying"
+	StateSucceeded = "succeeded"
+	StateFailed    = "failed"
+)
+
+const (
+	statusFile       = "status.json"
+	statusLogSuffix  = ".log"
+	defaultRetention = 168
+)
+
+// IsState return true if s is a state of task
+func IsState(s string) bool {
+	switch s {
+	case StatePending, StateRunning, StateRetrying, StateSucceeded, StateFailed:
+		return true
+	}
+	return false
+}
+
+// IsFinished return true if the task in the state does not run any more
+func IsFinished(state string) bool {
+	return state == StateSucceeded || state == StateFailed
+}
+
+// Attempt one run of the executors of a task
+type Attempt struct {
+	Time int64 `json:"time"`
+	// Succeeded bits of the executors succeeded in this attempt
+	Succeeded int `json:"succeeded"`
+	// Failed bits of the executors failed in this attempt
+	Failed int `json:"failed"`
+	// Compensated bits of the executors undone when the task is abandoned
+	Compensated int    `json:"compensated,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TaskStatus status of a task
+type TaskStatus struct {
+	ID       string `json:"id"`
+	AppID    string `json:"appID"`
+	CreateBy string `json:"createBy"`
+	// Content bits of the executors required
+	Content int `json:"content"`
+	// Ret bits of the executors done
+	Ret       int       `json:"ret"`
+	State     string    `json:"state"`
+	Retry     int       `json:"retry"`
+	NextRetry int64     `json:"nextRetry,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+	Attempts  []Attempt `json:"attempts"`
+
+	CreateTime int64 `json:"createTime"`
+	UpdateTime int64 `json:"updateTime"`
+}
+
+// Registry records the status of the tasks
+type Registry interface {
+	Save(status *TaskStatus) error
+	Get(id string) (*TaskStatus, error)
+	// ListByApp return the tasks of the app, the latest first
+	ListByApp(appID string) ([]*TaskStatus, error)
+	// ListByState return a page of the tasks in the state, the latest first,
+	// and the number of the tasks in the state
+	ListByState(state string, page, limit int) ([]*TaskStatus, int64, error)
+}
+
+// newRegistry return the registry on the same backend with the queue,
+// finished tasks are kept for the retention.
+func newRegistry(c *config.Configs) (Registry, error) {
+	conf := c.Chaos.Queue
+	retention := c.Chaos.Registry.Retention
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	switch conf.Backend {
+	case "", QueueFile:
+		path := conf.Path
+		if path == "" {
+			path = c.CachePath + walSuffix
+		}
+		return newFileRegistry(filepath.Join(path, statusFile), retention*time.Hour)
+	case QueueRedis:
+		if redis2.ClusterClient == nil {
+			return nil, fmt.Errorf("redis is not initialized for the registry")
+		}
+		return newRedisRegistry(redis2.ClusterClient, conf, retention*time.Hour), nil
+	default:
+		return nil, fmt.Errorf("unknown queue backend %s", conf.Backend)
+	}
+}
+
+// fileRegistry keeps the status in memory, every change is appended
+// to a log beside the snapshot file. The log is folded into the snapshot
+// when it has more lines than the tasks, the expired tasks are dropped then.
+type fileRegistry struct {
+	mu        sync.RWMutex
+	path      string
+	retention time.Duration
+	status    map[string]*TaskStatus
+
+	log      *os.File
+	logLines int
+}
+
+func newFileRegistry(path string, retention time.Duration) (*fileRegistry, error) {
+	fr := &fileRegistry{
+		path:      path,
+		retention: retention,
+		status:    make(map[string]*TaskStatus),
+	}
+	body, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		list := make([]*TaskStatus, 0)
+		if err := json.Unmarshal(body, &list); err != nil {
+			return nil, err
+		}
+		for _, status := range list {
+			fr.status[status.ID] = status
+		}
+	}
+	if err := fr.replay(); err != nil {
+		return nil, err
+	}
+	if err := fr.compact(); err != nil {
+		return nil, err
+	}
+	return fr, nil
+}
+
+func (fr *fileRegistry) logPath() string {
+	return fr.path + statusLogSuffix
+}
+
+// replay apply the changes logged after the snapshot,
+// a line torn by a crash ends the log.
+func (fr *fileRegistry) replay() error {
+	f, err := os.Open(fr.logPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		status := &TaskStatus{}
+		if err := json.Unmarshal(scanner.Bytes(), status); err != nil {
+			break
+		}
+		fr.status[status.ID] = status
+	}
+	return nil
+}
+
+func (fr *fileRegistry) Save(status *TaskStatus) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if _, err := fr.log.Write(append(body, '\n')); err != nil {
+		return err
+	}
+	if err := fr.log.Sync(); err != nil {
+		return err
+	}
+	s := *status
+	fr.status[status.ID] = &s
+	fr.logLines++
+
+	if fr.logLines < compactThreshold || fr.logLines < len(fr.status) {
+		return nil
+	}
+	return fr.compact()
+}
+
+func (fr *fileRegistry) expire() {
+	deadline := time.Now().Add(-fr.retention).Unix()
+	for id, status := range fr.status {
+		if IsFinished(status.State) && status.UpdateTime < deadline {
+			delete(fr.status, id)
+		}
+	}
+}
+
+// compact write the snapshot and start an empty log,
+// a crash before the log is emptied replays it onto the new snapshot again.
+func (fr *fileRegistry) compact() error {
+	fr.expire()
+	list := make([]*TaskStatus, 0, len(fr.status))
+	for _, status := range fr.status {
+		list = append(list, status)
+	}
+	body, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(fr.path, body); err != nil {
+		return err
+	}
+
+	if fr.log != nil {
+		fr.log.Close()
+	}
+	f, err := os.OpenFile(fr.logPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fr.log = f
+	fr.logLines = 0
+	return nil
+}
+
+func (fr *fileRegistry) Get(id string) (*TaskStatus, error) {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	status, ok := fr.status[id]
+	if !ok {
+		return nil, nil
+	}
+	s := *status
+	return &s, nil
+}
+
+func (fr *fileRegistry) ListByApp(appID string) ([]*TaskStatus, error) {
+	return fr.list(func(status *TaskStatus) bool {
+		return status.AppID == appID
+	}), nil
+}
+
+func (fr *fileRegistry) ListByState(state string, page, limit int) ([]*TaskStatus, int64, error) {
+	list := fr.list(func(status *TaskStatus) bool {
+		return status.State == state
+	})
+	return pageOf(list, page, limit), int64(len(list)), nil
+}
+
+func (fr *fileRegistry) list(match func(*TaskStatus) bool) []*TaskStatus {
+	fr.mu.RLock()
+	defer fr.mu.RUnlock()
+
+	ret := make([]*TaskStatus, 0)
+	for _, status := range fr.status {
+		if match(status) {
+			s := *status
+			ret = append(ret, &s)
+		}
+	}
+	sortLatest(ret)
+	return ret
+}
+
+// pageOf return the page of the list
+func pageOf(list []*TaskStatus, page, limit int) []*TaskStatus {
+	p := page2.NewPage(page, limit, int64(len(list)))
+	if p.StartIndex < 0 || p.StartIndex >= len(list) {
+		return []*TaskStatus{}
+	}
+	end := p.StartIndex + p.PageSize
+	if end > len(list) {
+		end = len(list)
+	}
+	return list[p.StartIndex:end]
+}
+
+func sortLatest(list []*TaskStatus) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreateTime != list[j].CreateTime {
+			return list[i].CreateTime > list[j].CreateTime
+		}
+		return laterID(list[i].ID, list[j].ID)
+	})
+}
+
+// laterID report whether the task a is put after b, the ids are sequence numbers in decimal
+func laterID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
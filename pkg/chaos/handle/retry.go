@@ -0,0 +1,173 @@
+package handle
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+// class of error, retryable in config is a list of them
+const (
+	ErrClassTimeout = "timeout"
+	ErrClassNetwork = "network"
+	ErrClassServer  = "server" // 5xx
+	ErrClassClient  = "client" // 4xx
+	ErrClassOther   = "other"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 120
+	defaultFactor      = 2
+	defaultMaxDelay    = 3600
+)
+
+// retryPolicy decides whether and when a failed executor is run again.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	factor      float64
+	jitter      float64
+	maxDelay    time.Duration
+	// retryable classes of error, all are retryable if it is empty
+	retryable map[string]bool
+}
+
+// newRetryPolicies return the default policy and the policies by the bit of executor.
+// The omitted fields of the policy in chaos.executors inherit the one in chaos.retry.executors,
+// which inherits the default one, the omitted fields of the default one inherit
+// maximumRetry and waitTime if they are set.
+func newRetryPolicies(c *config.Configs) (*retryPolicy, map[int]*retryPolicy) {
+	legacy := config.RetryPolicy{}
+	if c.MaximumRetry > 0 {
+		legacy.MaxAttempts = intPtr(c.MaximumRetry)
+	}
+	if c.WaitTime > 0 {
+		legacy.BaseDelay = durationPtr(time.Duration(c.WaitTime) * 60)
+	}
+	base := mergePolicy(c.Chaos.Retry.Default, legacy)
+	base = mergePolicy(base, config.RetryPolicy{
+		MaxAttempts: intPtr(defaultMaxAttempts),
+		BaseDelay:   durationPtr(defaultBaseDelay),
+		Factor:      floatPtr(defaultFactor),
+		Jitter:      floatPtr(0),
+		MaxDelay:    durationPtr(defaultMaxDelay),
+	})
+
+	merged := make(map[int]config.RetryPolicy, len(c.Chaos.Retry.Executors))
+	for bit, p := range c.Chaos.Retry.Executors {
+		merged[bit] = mergePolicy(p, base)
+	}
+	for _, e := range c.Chaos.Executors {
+		parent, ok := merged[e.Bit]
+		if !ok {
+			parent = base
+		}
+		merged[e.Bit] = mergePolicy(e.Retry, parent)
+	}
+
+	policies := make(map[int]*retryPolicy, len(merged))
+	for bit, p := range merged {
+		policies[bit] = toRetryPolicy(p)
+	}
+	return toRetryPolicy(base), policies
+}
+
+// mergePolicy fill the omitted fields of p with the ones of parent
+func mergePolicy(p, parent config.RetryPolicy) config.RetryPolicy {
+	if p.MaxAttempts == nil {
+		p.MaxAttempts = parent.MaxAttempts
+	}
+	if p.BaseDelay == nil {
+		p.BaseDelay = parent.BaseDelay
+	}
+	if p.Factor == nil {
+		p.Factor = parent.Factor
+	}
+	if p.Jitter == nil {
+		p.Jitter = parent.Jitter
+	}
+	if p.MaxDelay == nil {
+		p.MaxDelay = parent.MaxDelay
+	}
+	if p.Retryable == nil {
+		p.Retryable = parent.Retryable
+	}
+	return p
+}
+
+// toRetryPolicy p is merged with the defaults, none of its fields is nil
+func toRetryPolicy(p config.RetryPolicy) *retryPolicy {
+	rp := &retryPolicy{
+		maxAttempts: *p.MaxAttempts,
+		baseDelay:   *p.BaseDelay * time.Second,
+		factor:      *p.Factor,
+		jitter:      math.Max(math.Min(*p.Jitter, 1), 0),
+		maxDelay:    *p.MaxDelay * time.Second,
+	}
+	if len(p.Retryable) != 0 {
+		rp.retryable = make(map[string]bool, len(p.Retryable))
+		for _, class := range p.Retryable {
+			rp.retryable[class] = true
+		}
+	}
+	return rp
+}
+
+// canRetry return true if the executor failed attempts times with err can run again.
+func (rp *retryPolicy) canRetry(attempts int, err error) bool {
+	if attempts >= rp.maxAttempts {
+		return false
+	}
+	return rp.retryable == nil || rp.retryable[classify(err)]
+}
+
+// delay return the delay before the next attempt, attempts is the number of failures.
+// It grows by factor from baseDelay up to maxDelay, and is spread by jitter.
+func (rp *retryPolicy) delay(attempts int) time.Duration {
+	d := float64(rp.baseDelay) * math.Pow(rp.factor, float64(attempts-1))
+	if max := float64(rp.maxDelay); d > max {
+		d = max
+	}
+	if rp.jitter > 0 {
+		d += d * rp.jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+func intPtr(v int) *int { return &v }
+
+func floatPtr(v float64) *float64 { return &v }
+
+func durationPtr(v time.Duration) *time.Duration { return &v }
+
+// classify return the class of err.
+func classify(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrClassTimeout
+	}
+	var status *define.StatusError
+	if errors.As(err, &status) {
+		if status.Code >= 500 {
+			return ErrClassServer
+		}
+		if status.Code >= 400 {
+			return ErrClassClient
+		}
+		return ErrClassOther
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ErrClassTimeout
+		}
+		return ErrClassNetwork
+	}
+	return ErrClassOther
+}
@@ -0,0 +1,137 @@
+package handle
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+func TestRetryDelay(t *testing.T) {
+	rp := &retryPolicy{
+		maxAttempts: 10,
+		baseDelay:   10 * time.Second,
+		factor:      2,
+		maxDelay:    time.Minute,
+	}
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{9, time.Minute},
+	}
+	for _, c := range cases {
+		if got := rp.delay(c.attempts); got != c.want {
+			t.Errorf("delay(%d) = %s, want %s", c.attempts, got, c.want)
+		}
+	}
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	rp := &retryPolicy{
+		baseDelay: 100 * time.Second,
+		factor:    2,
+		jitter:    0.2,
+		maxDelay:  time.Hour,
+	}
+	for i := 0; i < 1000; i++ {
+		d := rp.delay(2)
+		if d < 160*time.Second || d > 240*time.Second {
+			t.Fatalf("delay = %s, want within 20%% of 200s", d)
+		}
+	}
+}
+
+func TestRetryCanRetry(t *testing.T) {
+	rp := &retryPolicy{
+		maxAttempts: 2,
+		retryable:   map[string]bool{ErrClassServer: true},
+	}
+	server := &define.StatusError{Code: 502}
+	client := &define.StatusError{Code: 400}
+	if !rp.canRetry(1, server) {
+		t.Error("a server error is not retried")
+	}
+	if rp.canRetry(1, client) {
+		t.Error("a client error is retried")
+	}
+	if rp.canRetry(2, server) {
+		t.Error("the executor is retried after maxAttempts failures")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ErrClassTimeout},
+		{&define.StatusError{Code: 503}, ErrClassServer},
+		{&define.StatusError{Code: 404}, ErrClassClient},
+		{fmt.Errorf("unknown"), ErrClassOther},
+	}
+	for _, c := range cases {
+		if got := classify(c.err); got != c.want {
+			t.Errorf("classify(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetryPoliciesInherit(t *testing.T) {
+	c := &config.Configs{}
+	c.Chaos.Retry.Default = config.RetryPolicy{
+		MaxAttempts: intPtr(4),
+		BaseDelay:   durationPtr(60),
+		Jitter:      floatPtr(0.5),
+	}
+	c.Chaos.Retry.Executors = map[int]config.RetryPolicy{
+		1: {Jitter: floatPtr(0)},
+	}
+	c.Chaos.Executors = []config.Executor{
+		{Bit: 1, Retry: config.RetryPolicy{MaxAttempts: intPtr(0)}},
+		{Bit: 2, Retry: config.RetryPolicy{BaseDelay: durationPtr(5)}},
+	}
+
+	base, policies := newRetryPolicies(c)
+	if base.maxAttempts != 4 || base.baseDelay != time.Minute || base.jitter != 0.5 ||
+		base.factor != defaultFactor || base.maxDelay != defaultMaxDelay*time.Second {
+		t.Fatalf("default policy = %+v", base)
+	}
+
+	// zero fields are kept rather than inherited
+	p1 := policies[1]
+	if p1.maxAttempts != 0 || p1.jitter != 0 || p1.baseDelay != time.Minute {
+		t.Fatalf("policy of bit 1 = %+v", p1)
+	}
+	if p1.canRetry(0, fmt.Errorf("failed")) {
+		t.Error("an executor with maxAttempts 0 is retried")
+	}
+	if d := p1.delay(1); d != time.Minute {
+		t.Errorf("delay without jitter = %s, want 1m", d)
+	}
+
+	p2 := policies[2]
+	if p2.baseDelay != 5*time.Second || p2.maxAttempts != 4 || p2.jitter != 0.5 {
+		t.Fatalf("policy of bit 2 = %+v", p2)
+	}
+}
+
+func TestRetryPoliciesLegacy(t *testing.T) {
+	c := &config.Configs{MaximumRetry: 6, WaitTime: 2}
+	base, _ := newRetryPolicies(c)
+	if base.maxAttempts != 6 || base.baseDelay != 2*time.Minute {
+		t.Fatalf("default policy = %+v, want maximumRetry and waitTime", base)
+	}
+
+	base, _ = newRetryPolicies(&config.Configs{})
+	if base.maxAttempts != defaultMaxAttempts || base.baseDelay != defaultBaseDelay*time.Second {
+		t.Fatalf("default policy = %+v, want the defaults", base)
+	}
+}
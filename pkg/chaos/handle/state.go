@@ -0,0 +1,64 @@
+package handle
+
+import (
+	"context"
+	"sync"
+)
+
+// taskState the values kept by the executors across the attempts of a task,
+// it is persisted with the task so a retry finds the work of the last attempt.
+type taskState struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+type stateKey struct{}
+
+// withState let the executors read and set the state of the task.
+func withState(ctx context.Context, values map[string]string) (context.Context, *taskState) {
+	s := &taskState{values: make(map[string]string, len(values))}
+	for k, v := range values {
+		s.values[k] = v
+	}
+	return context.WithValue(ctx, stateKey{}, s), s
+}
+
+func (s *taskState) snapshot() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.values) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(s.values))
+	for k, v := range s.values {
+		ret[k] = v
+	}
+	return ret
+}
+
+// State return the value of key set by an attempt of the task, empty if it is not set.
+func State(ctx context.Context, key string) string {
+	s, ok := ctx.Value(stateKey{}).(*taskState)
+	if !ok {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// SetState keep the value of key for the next attempts of the task, an empty value removes it.
+// The executors running in parallel use their own keys.
+func SetState(ctx context.Context, key, value string) {
+	s, ok := ctx.Value(stateKey{}).(*taskState)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if value == "" {
+		delete(s.values, key)
+		return
+	}
+	s.values[key] = value
+}
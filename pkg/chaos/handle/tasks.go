@@ -1,138 +1,101 @@
 package handle
 
 import (
-	"bytes"
+	"bufio"
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
-	"syscall"
+	"strconv"
+	"strings"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
 )
 
-type taskQueue struct {
-	cachePath string
-}
+// migratedSuffix the marker next to the legacy cache file,
+// it holds the number of lines already put into the queue.
+const migratedSuffix = ".migrated"
+
+// migrateLegacy move the tasks of the json lines cache file used
+// before the write-ahead-log into the queue, and remove the file.
+// The marker is written after each put, so a restart in the middle
+// goes on from the first line which was not moved.
+func migrateLegacy(cachePath string, q queue) (bool, error) {
+	marker := cachePath + migratedSuffix
+	done, err := readMarker(marker)
+	if err != nil {
+		return false, err
+	}
 
-func newTaskQueue(cachePath string) (*taskQueue, bool, error) {
-	var init bool = false
-	if _, err := os.Stat(cachePath); err != nil {
+	f, err := os.Open(cachePath)
+	if err != nil {
 		if os.IsNotExist(err) {
-			_, err := os.Create(cachePath)
-			if err != nil {
-				return nil, false, err
+			// the file was removed but the marker was not
+			if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+				return false, err
 			}
-			init = true
-		} else {
-			return nil, false, err
+			return false, nil
 		}
+		return false, err
 	}
+	defer f.Close()
 
-	return &taskQueue{
-		cachePath: cachePath,
-	}, init, nil
-}
-
-func (tq *taskQueue) put(data interface{}) error {
-	f, err := os.OpenFile(tq.cachePath, os.O_WRONLY|os.O_APPEND, 0755)
+	fi, err := f.Stat()
 	if err != nil {
-		return err
+		return false, err
 	}
-
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH); err != nil {
-		return err
+	if fi.IsDir() {
+		return false, nil
 	}
 
-	cache, err := json.Marshal(data)
-	if err != nil {
-		return err
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if line <= done {
+			continue
+		}
+		task := &data{}
+		if err := json.Unmarshal(scanner.Bytes(), task); err != nil {
+			continue
+		}
+		task.ID = ""
+		if err := q.put(task); err != nil {
+			return false, err
+		}
+		if err := writeFile(marker, []byte(strconv.Itoa(line))); err != nil {
+			return false, err
+		}
 	}
-
-	if _, err := f.WriteString(string(cache) + "\n"); err != nil {
-		return err
+	if err := scanner.Err(); err != nil {
+		return false, err
 	}
-
-	if err := f.Sync(); err != nil {
-		return err
+	if err := os.Remove(cachePath); err != nil {
+		return false, err
 	}
-
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
-		return err
+	if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+		return false, err
 	}
-
-	return nil
+	return true, nil
 }
 
-func (tq *taskQueue) pop(n int) ([][]byte, error) {
-	f, err := os.OpenFile(tq.cachePath, os.O_RDWR, 0755)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
-		return nil, err
-	}
-
-	defer func() {
-		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
-	}()
-
-	fi, err := f.Stat()
+// readMarker return the number of lines recorded in the marker, 0 if there is no marker.
+func readMarker(path string) (int, error) {
+	body, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
-	}
-
-	if fi.Size() > 0 {
-		buf := bytes.NewBuffer(make([]byte, 0, fi.Size()))
-
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return nil, err
-		}
-
-		if _, err := io.Copy(buf, f); err != nil {
-			return nil, err
-		}
-
-		ret := make([][]byte, 0, n)
-		for i := 0; i < n; i++ {
-			line, err := buf.ReadBytes('\n')
-			if err != nil && err != io.EOF {
-				return nil, err
-			}
-			if err == io.EOF {
-				break
-			}
-
-			ret = append(ret, line)
-		}
-
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return nil, err
-		}
-
-		nw, err := io.Copy(f, buf)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := f.Truncate(nw); err != nil {
-			return nil, err
-		}
-
-		if err := f.Sync(); err != nil {
-			return nil, err
+		if os.IsNotExist(err) {
+			return 0, nil
 		}
-
-		return ret, nil
+		return 0, err
 	}
-
-	return nil, fmt.Errorf("no data to pop")
+	return strconv.Atoi(strings.TrimSpace(string(body)))
 }
 
 type serializeCTX struct {
 	RequestID interface{} `json:"requestID"`
 	Timezone  interface{} `json:"timezone"`
 	TenantID  interface{} `json:"tenantID"`
+	// Trace the w3c trace context of the caller
+	Trace map[string]string `json:"trace,omitempty"`
 }
 
 var (
@@ -146,6 +109,7 @@ func marshalCTXHeader(c context.Context) serializeCTX {
 		RequestID: c.Value(_requestID),
 		Timezone:  c.Value(_timezone),
 		TenantID:  c.Value(_tenantID),
+		Trace:     tracing.Inject(c),
 	}
 }
 
@@ -154,5 +118,5 @@ func unmarshalCTXHeader(c serializeCTX) context.Context {
 	ctx = context.WithValue(ctx, _requestID, c.RequestID)
 	ctx = context.WithValue(ctx, _timezone, c.Timezone)
 	ctx = context.WithValue(ctx, _tenantID, c.TenantID)
-	return ctx
+	return tracing.Extract(ctx, c.Trace)
 }
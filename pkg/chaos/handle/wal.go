@@ -0,0 +1,528 @@
+package handle
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	walSuffix  = ".wal"
+	walTmp     = ".tmp"
+	headerSize = 8
+
+	defaultSegmentSize = 4 << 20
+	// maxRecordSize a larger size in a header is a torn write rather than a record
+	maxRecordSize = 16 << 20
+	// compact when the stale records are more than the live ones and this number
+	compactThreshold = 1024
+)
+
+// op of wal record
+const (
+	opPut byte = iota + 1
+	opAck
+	opSeq // keep the sequence of ids across compactions
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	errTornWrite      = errors.New("torn write")
+	errRecordTooLarge = errors.New("record is too large")
+)
+
+type walRecord struct {
+	Op   byte   `json:"op"`
+	ID   string `json:"id"`
+	Data *data  `json:"data,omitempty"`
+}
+
+type walEntry struct {
+	seq    uint64
+	data   data
+	leased bool
+}
+
+// walQueue is a write-ahead-log task queue.
+// Every put, ack and nack is appended to the active segment and synced
+// before returning, a restart replays the segments and only the tasks
+// without an ack come back. Tasks popped from the queue are leased
+// to the caller until they are acked or nacked.
+type walQueue struct {
+	mu sync.Mutex
+
+	dir         string
+	segmentSize int64
+	segments    []uint64
+
+	active     *os.File
+	activeSize int64
+	// err is set when a failed append can not be rolled back,
+	// the queue refuses to append until a restart drops the torn tail.
+	err error
+
+	seq     uint64
+	entries map[string]*walEntry
+	stale   int
+}
+
+// newWALQueue open the queue in dir, init is true when the dir is created.
+func newWALQueue(dir string, segmentSize int64) (*walQueue, bool, error) {
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+
+	var init bool
+	if _, err := os.Stat(dir); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, false, err
+		}
+		init = true
+	}
+
+	wq := &walQueue{
+		dir:         dir,
+		segmentSize: segmentSize,
+		entries:     make(map[string]*walEntry),
+	}
+	if err := wq.replay(); err != nil {
+		return nil, false, err
+	}
+	if err := wq.openActive(); err != nil {
+		return nil, false, err
+	}
+	return wq, init, nil
+}
+
+func (wq *walQueue) segmentPath(id uint64) string {
+	return filepath.Join(wq.dir, fmt.Sprintf("%016d%s", id, walSuffix))
+}
+
+func (wq *walQueue) replay() error {
+	files, err := ioutil.ReadDir(wq.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := f.Name()
+		if strings.HasSuffix(name, walTmp) {
+			// unfinished compaction
+			os.Remove(filepath.Join(wq.dir, name))
+			continue
+		}
+		if !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, walSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		wq.segments = append(wq.segments, id)
+	}
+	sort.Slice(wq.segments, func(i, j int) bool { return wq.segments[i] < wq.segments[j] })
+
+	for i, id := range wq.segments {
+		last := i == len(wq.segments)-1
+		if err := wq.replaySegment(id, last); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wq *walQueue) replaySegment(id uint64, last bool) error {
+	f, err := os.OpenFile(wq.segmentPath(id), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		record, n, err := readRecord(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if last {
+				// the tail of the last segment was not fully written before a crash
+				return f.Truncate(offset)
+			}
+			return fmt.Errorf("segment %d is corrupted at %d: %w", id, offset, err)
+		}
+		offset += n
+		wq.apply(record)
+	}
+}
+
+func readRecord(r io.Reader) (*walRecord, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, errTornWrite
+	}
+	size := binary.BigEndian.Uint32(header[:4])
+	sum := binary.BigEndian.Uint32(header[4:])
+	if size > maxRecordSize {
+		return nil, 0, errTornWrite
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, errTornWrite
+	}
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, 0, errTornWrite
+	}
+
+	record := &walRecord{}
+	if err := json.Unmarshal(body, record); err != nil {
+		return nil, 0, err
+	}
+	return record, int64(headerSize + size), nil
+}
+
+func encodeRecord(record *walRecord) ([]byte, error) {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxRecordSize {
+		return nil, errRecordTooLarge
+	}
+	buf := make([]byte, headerSize+len(body))
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:headerSize], crc32.Checksum(body, crcTable))
+	copy(buf[headerSize:], body)
+	return buf, nil
+}
+
+func (wq *walQueue) apply(record *walRecord) {
+	if seq, err := strconv.ParseUint(record.ID, 10, 64); err == nil && seq > wq.seq {
+		wq.seq = seq
+	}
+
+	switch record.Op {
+	case opPut:
+		if record.Data == nil {
+			return
+		}
+		if e, ok := wq.entries[record.ID]; ok {
+			e.data = *record.Data
+			wq.stale++
+			return
+		}
+		seq, _ := strconv.ParseUint(record.ID, 10, 64)
+		wq.entries[record.ID] = &walEntry{
+			seq:  seq,
+			data: *record.Data,
+		}
+	case opSeq:
+		// sequence is taken from the id above
+	case opAck:
+		if _, ok := wq.entries[record.ID]; ok {
+			delete(wq.entries, record.ID)
+			// the put and the ack
+			wq.stale += 2
+		}
+	}
+}
+
+func (wq *walQueue) openActive() error {
+	if len(wq.segments) == 0 {
+		wq.segments = append(wq.segments, 1)
+	}
+	id := wq.segments[len(wq.segments)-1]
+	f, err := os.OpenFile(wq.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	wq.active = f
+	wq.activeSize = fi.Size()
+	return nil
+}
+
+func (wq *walQueue) rotate() error {
+	if err := wq.active.Close(); err != nil {
+		return err
+	}
+	wq.segments = append(wq.segments, wq.segments[len(wq.segments)-1]+1)
+	return wq.openActive()
+}
+
+func (wq *walQueue) append(record *walRecord) error {
+	if wq.err != nil {
+		return wq.err
+	}
+	buf, err := encodeRecord(record)
+	if err != nil {
+		return err
+	}
+	_, err = wq.active.Write(buf)
+	if err == nil {
+		err = wq.active.Sync()
+	}
+	if err != nil {
+		// a partial record is cut off, the next one follows the last good record
+		if terr := wq.active.Truncate(wq.activeSize); terr != nil {
+			wq.err = fmt.Errorf("segment is torn at %d: %v", wq.activeSize, terr)
+		}
+		return err
+	}
+	wq.activeSize += int64(len(buf))
+
+	if wq.activeSize >= wq.segmentSize {
+		return wq.rotate()
+	}
+	return nil
+}
+
+// put persist the task, the id of d is set if it is empty.
+func (wq *walQueue) put(d *data) error {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	if d.ID == "" {
+		wq.seq++
+		d.ID = strconv.FormatUint(wq.seq, 10)
+	}
+	if err := wq.append(&walRecord{Op: opPut, ID: d.ID, Data: d}); err != nil {
+		return err
+	}
+
+	if e, ok := wq.entries[d.ID]; ok {
+		e.data = *d
+		e.leased = false
+		wq.stale++
+		return wq.maybeCompact()
+	}
+	seq, _ := strconv.ParseUint(d.ID, 10, 64)
+	wq.entries[d.ID] = &walEntry{
+		seq:  seq,
+		data: *d,
+	}
+	return nil
+}
+
+// pop lease at most n tasks which are due.
+func (wq *walQueue) pop(n int) ([]data, error) {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	now := time.Now().Unix()
+	ready := make([]*walEntry, 0, n)
+	for _, e := range wq.entries {
+		if !e.leased && e.data.Time <= now {
+			ready = append(ready, e)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].seq < ready[j].seq })
+	if len(ready) > n {
+		ready = ready[:n]
+	}
+
+	ret := make([]data, 0, len(ready))
+	for _, e := range ready {
+		e.leased = true
+		ret = append(ret, e.data)
+	}
+	return ret, nil
+}
+
+// ack remove the task from the queue.
+func (wq *walQueue) ack(id string) error {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	if _, ok := wq.entries[id]; !ok {
+		return nil
+	}
+	if err := wq.append(&walRecord{Op: opAck, ID: id}); err != nil {
+		return err
+	}
+	delete(wq.entries, id)
+	wq.stale += 2
+	return wq.maybeCompact()
+}
+
+// nack persist the new state of the task and give the lease back.
+func (wq *walQueue) nack(d data) error {
+	return wq.put(&d)
+}
+
+// next return the earliest time of the tasks which are not due.
+func (wq *walQueue) next() (int64, bool, error) {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+
+	now := time.Now().Unix()
+	var t int64
+	ok := false
+	for _, e := range wq.entries {
+		if e.leased || e.data.Time <= now {
+			continue
+		}
+		if !ok || e.data.Time < t {
+			t, ok = e.data.Time, true
+		}
+	}
+	return t, ok, nil
+}
+
+// len return the number of the tasks which are not acked.
+func (wq *walQueue) len() (int, error) {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+	return len(wq.entries), nil
+}
+
+func (wq *walQueue) maybeCompact() error {
+	if wq.stale < compactThreshold || wq.stale < len(wq.entries) {
+		return nil
+	}
+	return wq.compact()
+}
+
+// compact write the live tasks into a new segment and remove the old ones.
+func (wq *walQueue) compact() error {
+	id := wq.segments[len(wq.segments)-1] + 1
+	tmp := wq.segmentPath(id) + walTmp
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	buf, err := encodeRecord(&walRecord{Op: opSeq, ID: strconv.FormatUint(wq.seq, 10)})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := w.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	for key, e := range wq.entries {
+		d := e.data
+		buf, err := encodeRecord(&walRecord{Op: opPut, ID: key, Data: &d})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write(buf); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, wq.segmentPath(id)); err != nil {
+		return err
+	}
+	if err := syncDir(wq.dir); err != nil {
+		return err
+	}
+
+	if err := wq.active.Close(); err != nil {
+		return err
+	}
+	for _, old := range wq.segments {
+		if err := os.Remove(wq.segmentPath(old)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	wq.segments = []uint64{id}
+	wq.stale = 0
+	return wq.openActive()
+}
+
+func (wq *walQueue) ping(ctx context.Context) error {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+	if wq.err != nil {
+		return wq.err
+	}
+	f, err := ioutil.TempFile(wq.dir, "ping*"+walTmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte{0})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if rerr := os.Remove(f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (wq *walQueue) close() error {
+	wq.mu.Lock()
+	defer wq.mu.Unlock()
+	return wq.active.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// writeFile replace the file with body through a temporary file,
+// a crash leaves either the old or the new content.
+func writeFile(path string, body []byte) error {
+	tmp := path + walTmp
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,247 @@
+package handle
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func openWAL(t *testing.T, dir string, segmentSize int64) *walQueue {
+	t.Helper()
+	wq, _, err := newWALQueue(dir, segmentSize)
+	if err != nil {
+		t.Fatalf("open wal: %v", err)
+	}
+	return wq
+}
+
+func putTasks(t *testing.T, wq *walQueue, n int) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		d := &data{Retry: i}
+		if err := wq.put(d); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+func lastSegment(wq *walQueue) string {
+	return wq.segmentPath(wq.segments[len(wq.segments)-1])
+}
+
+func TestWALReplay(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 0)
+	ids := putTasks(t, wq, 3)
+	if err := wq.ack(ids[1]); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if err := wq.nack(data{ID: ids[2], Retry: 7}); err != nil {
+		t.Fatalf("nack: %v", err)
+	}
+	wq.close()
+
+	wq = openWAL(t, dir, 0)
+	defer wq.close()
+	if n, _ := wq.len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+	if _, ok := wq.entries[ids[1]]; ok {
+		t.Fatalf("acked task %s is replayed", ids[1])
+	}
+	if e := wq.entries[ids[2]]; e == nil || e.data.Retry != 7 {
+		t.Fatalf("nacked task is not replayed with its last state: %+v", e)
+	}
+
+	// ids go on after a restart
+	d := &data{}
+	if err := wq.put(d); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if d.ID != "4" {
+		t.Fatalf("id = %s, want 4", d.ID)
+	}
+}
+
+func TestWALPopLeases(t *testing.T) {
+	wq := openWAL(t, filepath.Join(t.TempDir(), "queue"), 0)
+	defer wq.close()
+	putTasks(t, wq, 3)
+
+	first, _ := wq.pop(2)
+	if len(first) != 2 || first[0].ID != "1" || first[1].ID != "2" {
+		t.Fatalf("pop = %+v, want tasks 1 and 2", first)
+	}
+	second, _ := wq.pop(2)
+	if len(second) != 1 || second[0].ID != "3" {
+		t.Fatalf("pop = %+v, want task 3", second)
+	}
+	if err := wq.nack(first[0]); err != nil {
+		t.Fatalf("nack: %v", err)
+	}
+	third, _ := wq.pop(2)
+	if len(third) != 1 || third[0].ID != "1" {
+		t.Fatalf("pop = %+v, want the nacked task 1", third)
+	}
+}
+
+func TestWALRotate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 256)
+	putTasks(t, wq, 10)
+	if len(wq.segments) < 2 {
+		t.Fatalf("segments = %d, want a rotation", len(wq.segments))
+	}
+	wq.close()
+
+	wq = openWAL(t, dir, 256)
+	defer wq.close()
+	if n, _ := wq.len(); n != 10 {
+		t.Fatalf("len = %d, want 10", n)
+	}
+}
+
+func TestWALCompact(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 4096)
+	ids := putTasks(t, wq, compactThreshold/2+2)
+	for _, id := range ids[2:] {
+		if err := wq.ack(id); err != nil {
+			t.Fatalf("ack: %v", err)
+		}
+	}
+	if len(wq.segments) != 1 || wq.stale != 0 {
+		t.Fatalf("segments = %v, stale = %d, want a compaction", wq.segments, wq.stale)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("files = %d, want the compacted segment only", len(files))
+	}
+	wq.close()
+
+	wq = openWAL(t, dir, 4096)
+	defer wq.close()
+	if n, _ := wq.len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+	d := &data{}
+	if err := wq.put(d); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if want := len(ids) + 1; d.ID != strconv.Itoa(want) {
+		t.Fatalf("id = %s, want %d after the compaction", d.ID, want)
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 0)
+	putTasks(t, wq, 2)
+	path := lastSegment(wq)
+	wq.close()
+
+	fi, _ := os.Stat(path)
+	good := fi.Size()
+	buf, err := encodeRecord(&walRecord{Op: opPut, ID: "3", Data: &data{ID: "3"}})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	appendBytes(t, path, buf[:len(buf)/2])
+
+	wq = openWAL(t, dir, 0)
+	if n, _ := wq.len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+	if fi, _ := os.Stat(path); fi.Size() != good {
+		t.Fatalf("size = %d, want the torn tail cut at %d", fi.Size(), good)
+	}
+	putTasks(t, wq, 1)
+	wq.close()
+
+	wq = openWAL(t, dir, 0)
+	defer wq.close()
+	if n, _ := wq.len(); n != 3 {
+		t.Fatalf("len = %d, want 3", n)
+	}
+}
+
+func TestWALOversizedRecord(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 0)
+	putTasks(t, wq, 1)
+	path := lastSegment(wq)
+	wq.close()
+
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[:4], maxRecordSize+1)
+	appendBytes(t, path, header)
+
+	wq = openWAL(t, dir, 0)
+	defer wq.close()
+	if n, _ := wq.len(); n != 1 {
+		t.Fatalf("len = %d, want 1", n)
+	}
+}
+
+func TestWALCorruptedSegment(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "queue")
+	wq := openWAL(t, dir, 256)
+	putTasks(t, wq, 10)
+	first := wq.segmentPath(wq.segments[0])
+	wq.close()
+
+	appendBytes(t, first, []byte{0, 0})
+	if _, _, err := newWALQueue(dir, 256); err == nil {
+		t.Fatal("a torn record in the middle of the log is not reported")
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache")
+	lines := []string{`{"retry":1}`, `{"retry":2}`, `{"retry":3}`}
+	if err := ioutil.WriteFile(cachePath, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the first two lines were moved before a crash
+	if err := ioutil.WriteFile(cachePath+migratedSuffix, []byte("2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wq := openWAL(t, filepath.Join(dir, "queue"), 0)
+	defer wq.close()
+	migrated, err := migrateLegacy(cachePath, wq)
+	if err != nil || !migrated {
+		t.Fatalf("migrate = %v, %v", migrated, err)
+	}
+	if n, _ := wq.len(); n != 1 {
+		t.Fatalf("len = %d, want the line which was not moved", n)
+	}
+	if e := wq.entries["1"]; e == nil || e.data.Retry != 3 {
+		t.Fatalf("task = %+v, want the third line", e)
+	}
+	for _, path := range []string{cachePath, cachePath + migratedSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s is not removed", path)
+		}
+	}
+}
+
+func appendBytes(t *testing.T, path string, b []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -38,7 +38,7 @@ type appCenter struct {
 // NewAppCenter NewAppCenter
 func NewAppCenter(conf client.Config) AppCenter {
 	return &appCenter{
-		client: client.New(conf),
+		client: NewHTTPClient(conf),
 	}
 }
 
@@ -1,35 +1,56 @@
 package client
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/quanxiang-cloud/appcenter/pkg/chaos/define"
 	"github.com/quanxiang-cloud/appcenter/pkg/config"
 	"github.com/quanxiang-cloud/cabin/tailormade/client"
+	"github.com/quanxiang-cloud/cabin/tailormade/header"
 )
 
 // ChaoURL
 const (
 	ChaosURL = "http://localhost:6666/init"
+
+	chaosEventsURL = "http://localhost:6666/events/%s"
+	// ChaosLivenessURL ChaosLivenessURL
+	ChaosLivenessURL = "http://localhost:6666/liveness"
 )
 
 // NewChaos new
 func NewChaos(c *config.Configs) Chaos {
+	cli := NewHTTPClient(c.InternalNet)
+	// the stream lasts as long as the initialization, it is ended by ctx
+	stream := cli
+	stream.Timeout = 0
 	return &chaos{
-		client: client.New(c.InternalNet),
+		client: cli,
+		stream: stream,
 	}
 }
 
 type chaos struct {
 	client http.Client
+	stream http.Client
 }
 
 // Chaos Chaos
 type Chaos interface {
 	Init(ctx context.Context, req *InitReq) error
+	// Watch return the progress of the initialization of the app,
+	// the channel is closed when the task is finished or ctx is done.
+	Watch(ctx context.Context, appID string) (<-chan Event, error)
 }
 
+// Event Event
+type Event = define.Event
+
 // InitReq req
 type InitReq = []Msg
 
@@ -43,3 +64,51 @@ type InitResp = define.Response
 func (c *chaos) Init(ctx context.Context, req *InitReq) error {
 	return client.POST(ctx, &c.client, ChaosURL, req, &InitResp{})
 }
+
+// Watch watch
+func (c *chaos) Watch(ctx context.Context, appID string) (<-chan Event, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(chaosEventsURL, appID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Add(header.GetRequestIDKV(ctx).Wreck())
+	req.Header.Add(header.GetTimezone(ctx).Wreck())
+	req.Header.Add(header.GetTenantID(ctx).Wreck())
+
+	response, err := c.stream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("watch %s: expected state value is 200, actually %d", appID, response.StatusCode)
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer response.Body.Close()
+
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
+			}
+			e := Event{}
+			if err := json.Unmarshal(bytes.TrimSpace(line[len("data:"):]), &e); err != nil {
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+			if e.Final {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
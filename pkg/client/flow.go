@@ -28,7 +28,7 @@ const (
 // NewFlow NewFlow
 func NewFlow(conf *config.Configs) Flow {
 	return &flow{
-		client:     client.New(conf.InternalNet),
+		client:     NewHTTPClient(conf.InternalNet),
 		innerHosts: conf.InnerHost,
 	}
 }
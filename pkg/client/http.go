@@ -0,0 +1,28 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/metrics"
+	"github.com/quanxiang-cloud/appcenter/pkg/tracing"
+	"github.com/quanxiang-cloud/cabin/tailormade/client"
+)
+
+// NewHTTPClient return the client of the internal net,
+// its requests are traced and measured by downstream host.
+func NewHTTPClient(conf client.Config) http.Client {
+	return tracing.Instrument(metrics.Instrument(client.New(conf)))
+}
@@ -32,7 +32,7 @@ const (
 // NewPolyAPI NewPolyAPI
 func NewPolyAPI(conf *config.Configs) PolyAPI {
 	return &polyapi{
-		client:     client.New(conf.InternalNet),
+		client:     NewHTTPClient(conf.InternalNet),
 		innerHosts: conf.InnerHost,
 	}
 }
@@ -55,6 +55,18 @@ type ErrNode struct {
 	Err   error  `json:"err"`
 }
 
+// CheckDelResp return err, or an error of the nodes which failed in resp
+func CheckDelResp(resp *DelResp, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp != nil && len(resp.Errors) != 0 {
+		node := resp.Errors[0]
+		return fmt.Errorf("%d errors, first at %s.%s: %v", len(resp.Errors), node.DB, node.Table, node.Err)
+	}
+	return nil
+}
+
 func (p *polyapi) DeleteAPP(ctx context.Context, appID string) (*DelResp, error) {
 	params := struct {
 	}{}
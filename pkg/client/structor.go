@@ -33,7 +33,7 @@ const (
 // NewStructor NewStructor
 func NewStructor(conf *config.Configs) Structor {
 	return &structor{
-		client:     client.New(conf.InternalNet),
+		client:     NewHTTPClient(conf.InternalNet),
 		innerHosts: conf.InnerHost,
 	}
 }
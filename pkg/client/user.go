@@ -49,7 +49,7 @@ type user struct {
 // NewUser init instance
 func NewUser(conf client.Config) User {
 	return &user{
-		client: client.New(conf),
+		client: NewHTTPClient(conf),
 	}
 }
 
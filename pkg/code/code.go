@@ -41,18 +41,33 @@ const (
 	ErrNoPermission = 90014000008
 	// ErrActionTimeOut Timeout
 	ErrActionTimeOut = 90014000010
+	// ErrAppPurging App is being purged
+	ErrAppPurging = 90014000011
+	// ErrInvalidStatus Invalid use status of app
+	ErrInvalidStatus = 90014000012
+	// ErrInvalidTransition The use status of app can not change to the target
+	ErrInvalidTransition = 90014000013
+	// ErrAppNotReady The servers of app are not initialized
+	ErrAppNotReady = 90014000014
+	// ErrWebhookURL The url of webhook is invalid or in the internal network
+	ErrWebhookURL = 90014000015
 )
 
 // CodeTable 码表
 var CodeTable = map[int64]string{
-	InvalidURI:         "无效的URI.",
-	InvalidParams:      "无效的参数.",
-	InvalidTimestamp:   "无效的时间格式.",
-	NameExist:          "名称已被使用！请检查后重试！",
-	InvalidDel:         "删除无效！对象不存在或请检查参数！",
-	ErrIdentifiesExist: "唯一标识已存在",
-	ErrVersion:         "版本不兼容",
-	ErrDataNotExist:    "数据不存在",
-	ErrNoPermission:    "没有权限",
-	ErrActionTimeOut:   "操作超时，稍后请再次尝试",
+	InvalidURI:           "无效的URI.",
+	InvalidParams:        "无效的参数.",
+	InvalidTimestamp:     "无效的时间格式.",
+	NameExist:            "名称已被使用！请检查后重试！",
+	InvalidDel:           "删除无效！对象不存在或请检查参数！",
+	ErrIdentifiesExist:   "唯一标识已存在",
+	ErrVersion:           "版本不兼容",
+	ErrDataNotExist:      "数据不存在",
+	ErrNoPermission:      "没有权限",
+	ErrActionTimeOut:     "操作超时，稍后请再次尝试",
+	ErrAppPurging:        "应用正在被彻底删除，无法恢复",
+	ErrInvalidStatus:     "无效的应用状态",
+	ErrInvalidTransition: "应用当前状态不允许此操作",
+	ErrAppNotReady:       "应用尚未完成初始化，无法发布",
+	ErrWebhookURL:        "webhook地址无效或位于内部网络",
 }
@@ -35,12 +35,169 @@ var DefaultPath = "./configs/config.yml"
 type AppCenter struct {
 	Model      string     `yaml:"model"`
 	HTTPServer HTTPServer `yaml:"http"`
+	Purge      Purge      `yaml:"purge"`
+	// InitTimeout seconds to wait for the initialization of an app
+	InitTimeout time.Duration `yaml:"initTimeout"`
+	Events      Events        `yaml:"events"`
+	Webhook     AppWebhook    `yaml:"webhook"`
+	Schedule    Schedule      `yaml:"schedule"`
+}
+
+// Schedule worker of the scheduled status changes of the apps
+type Schedule struct {
+	// Interval seconds between two scans of the due schedules
+	Interval time.Duration `yaml:"interval"`
+	// Batch schedules executed in a scan
+	Batch int `yaml:"batch"`
+}
+
+// AppWebhook delivery of the events to the webhooks of the apps
+type AppWebhook struct {
+	// Interval seconds between two scans of the deliveries
+	Interval time.Duration `yaml:"interval"`
+	// Batch deliveries attempted in a scan
+	Batch int `yaml:"batch"`
+	// MaxAttempts attempts before a delivery fails
+	MaxAttempts int `yaml:"maxAttempts"`
+	// Backoff seconds before the first retry, it doubles on each retry up to MaxBackoff
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// Timeout seconds of a delivery
+	Timeout time.Duration `yaml:"timeout"`
+	// DeniedNets networks the webhooks can not reach besides the loopback, private and link-local ones,
+	// e.g. the service network of the cluster
+	DeniedNets []string `yaml:"deniedNets"`
+}
+
+// Events relay of the domain events of the apps
+type Events struct {
+	// Interval milliseconds between two scans of the outbox
+	Interval time.Duration `yaml:"interval"`
+	// Batch events relayed in a scan
+	Batch int `yaml:"batch"`
+	// MaxRetry failures before an event is given up
+	MaxRetry int `yaml:"maxRetry"`
+	// Retention hours the relayed events are kept in the outbox
+	Retention time.Duration `yaml:"retention"`
+
+	Redis   EventStream  `yaml:"redis"`
+	Webhook EventWebhook `yaml:"webhook"`
+}
+
+// EventStream publish the events to a redis stream
+type EventStream struct {
+	Enabled bool   `yaml:"enabled"`
+	Stream  string `yaml:"stream"`
+	// MaxLen approximate length the stream is trimmed to, zero is unlimited
+	MaxLen int64 `yaml:"maxLen"`
+}
+
+// EventWebhook post the events to each of the urls
+type EventWebhook struct {
+	URLs []string `yaml:"urls"`
+	// Timeout seconds of a post
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Purge recycle bin purge worker
+type Purge struct {
+	// Interval minutes between two scans of the recycle bin
+	Interval time.Duration `yaml:"interval"`
+	// FlowRestoreStatus the status sent to flow to undo the preDelete of a restored app,
+	// flow is left as it is when it is empty.
+	FlowRestoreStatus string `yaml:"flowRestoreStatus"`
 }
 
 // Chaos Chaos
 type Chaos struct {
 	Model      string     `yaml:"model"`
 	HTTPServer HTTPServer `yaml:"http"`
+	Queue      Queue      `yaml:"queue"`
+	Registry   Registry   `yaml:"registry"`
+	Retry      Retry      `yaml:"retry"`
+	// Executors the executors run for a task, the ones of initServerBits in kv are used if it is empty
+	Executors []Executor `yaml:"executors"`
+	// DrainTimeout seconds to wait for the running executors on shutdown
+	DrainTimeout time.Duration `yaml:"drainTimeout"`
+}
+
+// Executor an executor of chaos
+type Executor struct {
+	// Name the name of the executor in the registry: form|polyapi|flow|structor
+	Name string `yaml:"name"`
+	// Bit the bit of the executor in initServerBits, it is unique
+	Bit int `yaml:"bit"`
+	// DependsOn bits of the executors which must succeed before it
+	DependsOn int `yaml:"dependsOn"`
+	// Endpoints URLs by the keys of the executor
+	Endpoints map[string]string `yaml:"endpoints"`
+	// Timeout seconds of a run, no timeout if it is zero
+	Timeout time.Duration `yaml:"timeout"`
+	// Retry omitted fields inherit the policy in chaos.retry
+	Retry RetryPolicy `yaml:"retry"`
+	// Webhook options of the executor named webhook
+	Webhook Webhook `yaml:"webhook"`
+}
+
+// Webhook options of the webhook executor
+type Webhook struct {
+	// Secret key of the HMAC-SHA256 signature, the body is not signed if it is empty
+	Secret string `yaml:"secret"`
+	// ExpectStatus status codes of success, any 2xx if it is empty
+	ExpectStatus []int `yaml:"expectStatus"`
+	// ExpectCode the code in the json body of success, the body is not checked if it is not set
+	ExpectCode *int64 `yaml:"expectCode"`
+}
+
+// Retry retry policies of the executors of chaos
+type Retry struct {
+	// Default the policy of the executors without their own,
+	// it inherits maximumRetry and waitTime
+	Default RetryPolicy `yaml:"default"`
+	// Executors the policies by the bit of executor, omitted fields inherit the default
+	Executors map[int]RetryPolicy `yaml:"executors"`
+}
+
+// RetryPolicy retry policy of an executor, a nil field is omitted
+// and inherits the parent policy, a zero one is kept.
+type RetryPolicy struct {
+	// MaxAttempts failures of the executor before the task is dead
+	MaxAttempts *int `yaml:"maxAttempts"`
+	// BaseDelay seconds before the first retry
+	BaseDelay *time.Duration `yaml:"baseDelay"`
+	// Factor the delay is multiplied by on every failure
+	Factor *float64 `yaml:"factor"`
+	// Jitter fraction of the delay to spread randomly, 0 to 1
+	Jitter *float64 `yaml:"jitter"`
+	// MaxDelay seconds of the cap of the delay
+	MaxDelay *time.Duration `yaml:"maxDelay"`
+	// Retryable classes of error to retry: timeout|network|server|client|other, all if empty
+	Retryable []string `yaml:"retryable"`
+}
+
+// Registry status of the tasks of chaos
+type Registry struct {
+	// Retention hours to keep the status of the finished tasks
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Queue task queue of chaos
+type Queue struct {
+	// Backend file|redis, file is for a single instance of chaos
+	Backend string `yaml:"backend"`
+
+	// Path directory of the write-ahead-log, default is cachePath with suffix ".wal"
+	Path string `yaml:"path"`
+	// SegmentSize bytes of a log segment before rolling to a new one
+	SegmentSize int64 `yaml:"segmentSize"`
+
+	// Stream key of the redis stream
+	Stream string `yaml:"stream"`
+	// Group consumer group of the instances of chaos
+	Group string `yaml:"group"`
+	// ClaimIdle seconds before a task pending on a dead instance is claimed by others,
+	// a live instance keeps its running tasks from being claimed however long they run.
+	ClaimIdle time.Duration `yaml:"claimIdle"`
 }
 
 // Configs Configs
@@ -56,6 +213,8 @@ type Configs struct {
 	InternalNet       client.Config   `yaml:"internalNet"`
 	Redis             redis2.Config   `yaml:"redis"`
 	InnerHost         InnerHostConfig `yaml:"innerHost"`
+	Probe             Probe           `yaml:"probe"`
+	Tracing           Tracing         `yaml:"tracing"`
 	CompatibleVersion string          `yaml:"compatibleVersion"`
 
 	InitServerBits int `yaml:"initServerBits"`
@@ -75,12 +234,33 @@ type InnerHostConfig struct {
 	OrgHost      string `yaml:"org"`
 }
 
+// Probe readiness checks
+type Probe struct {
+	// Timeout seconds each check may take
+	Timeout time.Duration `yaml:"timeout"`
+	// Downstream check the hosts of innerHost, their failures do not fail the readiness
+	Downstream bool `yaml:"downstream"`
+}
+
+// Tracing export of the spans
+type Tracing struct {
+	// Enabled export the spans, the trace context is propagated either way
+	Enabled bool `yaml:"enabled"`
+	// Endpoint host:port of the OTLP/HTTP collector
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// SampleRatio ratio of the traces sampled when the caller does not decide
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
 // HTTPServer HTTPServer
 type HTTPServer struct {
 	Port              string        `yaml:"port"`
 	ReadHeaderTimeOut time.Duration `yaml:"readHeaderTimeOut"`
 	WriteTimeOut      time.Duration `yaml:"writeTimeOut"`
 	MaxHeaderBytes    int           `yaml:"maxHeaderBytes"`
+	// ShutdownTimeout seconds to wait for the requests in progress on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
 }
 
 // Init Init
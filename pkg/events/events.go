@@ -0,0 +1,87 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+// types of the domain events of the apps
+const (
+	AppCreated       = "app.created"
+	AppPublished     = "app.published"
+	AppUnpublished   = "app.unpublished"
+	AppDeleted       = "app.deleted"
+	AppRestored      = "app.restored"
+	AppAdminsChanged = "app.admins.changed"
+	AppScopeChanged  = "app.scope.changed"
+)
+
+// Event a domain event of an app, it is delivered at least once,
+// the consumers deduplicate it by ID.
+type Event struct {
+	ID    string          `json:"id"`
+	Type  string          `json:"type"`
+	AppID string          `json:"appID"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	// Time unix seconds the event happened
+	Time int64 `json:"time"`
+}
+
+// Publisher a transport of the events
+type Publisher interface {
+	Publish(ctx context.Context, e *Event) error
+}
+
+// New return the publisher of the transports enabled in c
+func New(c *config.Configs, client redis.UniversalClient) Publisher {
+	conf := c.AppCenter.Events
+	var publishers fanout
+	if conf.Redis.Enabled {
+		publishers = append(publishers, NewStream(client, conf.Redis))
+	}
+	if len(conf.Webhook.URLs) != 0 {
+		publishers = append(publishers, NewWebhook(c.InternalNet, conf.Webhook))
+	}
+	return publishers
+}
+
+// Fanout return the publisher of all the publishers
+func Fanout(publishers ...Publisher) Publisher {
+	return fanout(publishers)
+}
+
+// fanout publish the events to all the transports, an event failing on any of them
+// is published again to all of them.
+type fanout []Publisher
+
+func (f fanout) Publish(ctx context.Context, e *Event) error {
+	var errs []string
+	for _, p := range f {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("publish %s %s: %s", e.Type, e.ID, strings.Join(errs, "; "))
+	}
+	return nil
+}
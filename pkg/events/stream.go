@@ -0,0 +1,59 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+const defaultStream = "appCenter:events"
+
+type stream struct {
+	client redis.UniversalClient
+	key    string
+	maxLen int64
+}
+
+// NewStream return the publisher appending the events to a redis stream,
+// each entry has the fields id, type, appID, time and data.
+func NewStream(client redis.UniversalClient, conf config.EventStream) Publisher {
+	key := conf.Stream
+	if key == "" {
+		key = defaultStream
+	}
+	return &stream{
+		client: client,
+		key:    key,
+		maxLen: conf.MaxLen,
+	}
+}
+
+func (s *stream) Publish(ctx context.Context, e *Event) error {
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.key,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]interface{}{
+			"id":    e.ID,
+			"type":  e.Type,
+			"appID": e.AppID,
+			"time":  e.Time,
+			"data":  string(e.Data),
+		},
+	}).Err()
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quanxiang-cloud/cabin/tailormade/client"
+
+	client2 "github.com/quanxiang-cloud/appcenter/pkg/client"
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+// headers of the webhook requests
+const (
+	HeaderEventID   = "X-Event-Id"
+	HeaderEventType = "X-Event-Type"
+	// HeaderTimestamp unix seconds the request is signed at
+	HeaderTimestamp = "X-Event-Timestamp"
+	// HeaderSignature sha256=hex(hmac-sha256(secret, timestamp + "." + body))
+	HeaderSignature = "X-Event-Signature"
+)
+
+const defaultWebhookTimeout = 5
+
+type webhook struct {
+	client http.Client
+	urls   []string
+}
+
+// NewWebhook return the publisher posting the events as json to each of the urls,
+// any status other than 2xx is a failure.
+func NewWebhook(conf client.Config, hook config.EventWebhook) Publisher {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	conf.Timeout = timeout
+	return &webhook{
+		client: client2.NewHTTPClient(conf),
+		urls:   hook.URLs,
+	}
+}
+
+func (w *webhook) Publish(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, url := range w.urls {
+		if _, err := Post(ctx, &w.client, url, "", e, body); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Post post the event encoded as body to url, it is signed when secret is not empty.
+// It return the status of the response, any status other than 2xx is a failure.
+func Post(ctx context.Context, c *http.Client, url, secret string, e *Event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, e.ID)
+	req.Header.Set(HeaderEventType, e.Type)
+	if secret != "" {
+		now := time.Now().Unix()
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+		req.Header.Set(HeaderSignature, Sign(secret, now, body))
+	}
+	rsp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		return rsp.StatusCode, fmt.Errorf("post %s: status %d", url, rsp.StatusCode)
+	}
+	return rsp.StatusCode, nil
+}
+
+// Sign return the signature of the body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+
+	// unmatched the route of the requests matching no route
+	unmatched = "unmatched"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the http requests by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	clientDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Latency of the outbound http requests by downstream host.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host", "method", "status"})
+
+	lockWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_lock_wait_seconds",
+		Help:    "Time spent waiting for a redis lock.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"key"})
+
+	executorTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chaos_executor_total",
+		Help: "Executions of the chaos executors by bit and result.",
+	}, []string{"bit", "result"})
+
+	executorDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "chaos_executor_duration_seconds",
+		Help:    "Latency of the chaos executors by bit.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"bit"})
+
+	retries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chaos_retries_total",
+		Help: "Retries of the chaos tasks by the bit of the failed executor.",
+	}, []string{"bit"})
+)
+
+// Handler serve the metrics in the prometheus format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Gin observe the latency and the status of the requests by route
+func Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatched
+		}
+		requestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Instrument observe the latency of the requests of the client by downstream host
+func Instrument(client http.Client) http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.Transport = &transport{next: next}
+	return client
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	clientDuration.
+		WithLabelValues(req.URL.Host, req.Method, status).
+		Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// ObserveLockWait observe the time from start until the lock of key is taken
+func ObserveLockWait(key string, start time.Time) {
+	lockWait.WithLabelValues(key).Observe(time.Since(start).Seconds())
+}
+
+// ObserveExecutor observe an execution of the executor of bit
+func ObserveExecutor(bit int, err error, start time.Time) {
+	label := strconv.Itoa(bit)
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	executorTotal.WithLabelValues(label, result).Inc()
+	executorDuration.WithLabelValues(label).Observe(time.Since(start).Seconds())
+}
+
+// IncRetry count a retry of the task caused by the executor of bit
+func IncRetry(bit int) {
+	retries.WithLabelValues(strconv.Itoa(bit)).Inc()
+}
+
+// QueueStats the depth of the queues of chaos
+type QueueStats struct {
+	// Memory tasks taken from the queue waiting for a worker
+	Memory int
+	// Durable tasks in the queue, including the delayed ones
+	Durable int
+	// DeadLetters tasks which used up the retries
+	DeadLetters int
+}
+
+// SetQueueStats set the source of the queue gauges, they are read on every scrape.
+func SetQueueStats(stats func() QueueStats) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.stats = stats
+}
+
+var queue = newQueueCollector()
+
+type queueCollector struct {
+	mu    sync.RWMutex
+	stats func() QueueStats
+
+	depth *prometheus.Desc
+	dead  *prometheus.Desc
+}
+
+func newQueueCollector() *queueCollector {
+	qc := &queueCollector{
+		depth: prometheus.NewDesc("chaos_queue_depth",
+			"Tasks waiting in the queue of chaos by location.", []string{"location"}, nil),
+		dead: prometheus.NewDesc("chaos_dead_letters",
+			"Tasks of chaos which used up the retries.", nil, nil),
+	}
+	prometheus.MustRegister(qc)
+	return qc
+}
+
+func (qc *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- qc.depth
+	ch <- qc.dead
+}
+
+func (qc *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	qc.mu.RLock()
+	stats := qc.stats
+	qc.mu.RUnlock()
+	if stats == nil {
+		return
+	}
+	s := stats()
+	ch <- prometheus.MustNewConstMetric(qc.depth, prometheus.GaugeValue, float64(s.Memory), "memory")
+	ch <- prometheus.MustNewConstMetric(qc.depth, prometheus.GaugeValue, float64(s.Durable), "durable")
+	ch <- prometheus.MustNewConstMetric(qc.dead, prometheus.GaugeValue, float64(s.DeadLetters))
+}
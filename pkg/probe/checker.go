@@ -0,0 +1,91 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// Checker check a dependency of the server on readiness
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (c *checkFunc) Name() string {
+	return c.name
+}
+
+func (c *checkFunc) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+// CheckFunc return a checker of name calling check
+func CheckFunc(name string, check func(ctx context.Context) error) Checker {
+	return &checkFunc{
+		name:  name,
+		check: check,
+	}
+}
+
+// DB ping the database
+func DB(db *gorm.DB) Checker {
+	return CheckFunc("mysql", func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// Redis ping redis
+func Redis(conn redis.UniversalClient) Checker {
+	return CheckFunc("redis", func(ctx context.Context) error {
+		return conn.Ping(ctx).Err()
+	})
+}
+
+// HTTP request the url, the host is reachable if it responds
+// with any status other than a server error.
+func HTTP(name, url string, client *http.Client) Checker {
+	return CheckFunc(name, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("expected state value is less than 500, actually %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
@@ -14,9 +14,13 @@ limitations under the License.
 package probe
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/quanxiang-cloud/cabin/logger"
 )
@@ -27,9 +31,50 @@ const (
 	readinessFalse
 )
 
+// status of Report
+const (
+	StatusReady       = "ready"
+	StatusPending     = "pending"
+	StatusShutdown    = "shutdown"
+	StatusUnavailable = "unavailable"
+)
+
+// status of CheckResult
+const (
+	CheckOK   = "ok"
+	CheckFail = "fail"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
 // Probe probe
 type Probe struct {
 	readiness int32
+
+	mu       sync.RWMutex
+	checkers []checker
+	timeout  time.Duration
+}
+
+type checker struct {
+	Checker
+	optional bool
+}
+
+// Report the readiness of the server and the result of each check
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult the result of a checker
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Optional the failure of the check does not fail the readiness
+	Optional  bool    `json:"optional,omitempty"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
 }
 
 // New return *Probe
@@ -37,7 +82,80 @@ func New() *Probe {
 	return &Probe{
 		// log:       log,
 		readiness: readinessPending,
+		timeout:   defaultCheckTimeout,
+	}
+}
+
+// SetTimeout set the time each check may take
+func (p *Probe) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timeout = timeout
+}
+
+// AddChecker add the checkers required by the readiness
+func (p *Probe) AddChecker(checkers ...Checker) {
+	p.add(false, checkers)
+}
+
+// AddOptional add the checkers which are reported, but do not fail the readiness
+func (p *Probe) AddOptional(checkers ...Checker) {
+	p.add(true, checkers)
+}
+
+func (p *Probe) add(optional bool, checkers []Checker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range checkers {
+		p.checkers = append(p.checkers, checker{Checker: c, optional: optional})
+	}
+}
+
+// Check run the checkers concurrently and return the report
+func (p *Probe) Check(ctx context.Context) *Report {
+	p.mu.RLock()
+	checkers := p.checkers
+	timeout := p.timeout
+	p.mu.RUnlock()
+
+	report := &Report{
+		Status: StatusReady,
+		Checks: make([]CheckResult, len(checkers)),
+	}
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c checker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Check(ctx)
+			result := CheckResult{
+				Name:      c.Name(),
+				Status:    CheckOK,
+				Optional:  c.optional,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = CheckFail
+				result.Error = err.Error()
+			}
+			report.Checks[i] = result
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == CheckFail && !result.Optional {
+			report.Status = StatusUnavailable
+		}
+	}
+	return report
 }
 
 func (p *Probe) setTrue() {
@@ -76,7 +194,8 @@ func (p *Probe) isSafe(r *http.Request) bool {
 	return false
 }
 
-// ReadinessProbe readiness probe
+// ReadinessProbe readiness probe, it responds the report of the checkers
+// once the server is running.
 func (p *Probe) ReadinessProbe(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("x-readiness-shutdown") != "" {
 		if !p.isSafe(r) {
@@ -85,14 +204,31 @@ func (p *Probe) ReadinessProbe(w http.ResponseWriter, r *http.Request) {
 		}
 		logger.Logger.Info("readiness shutdown")
 		p.setFalse()
-		w.WriteHeader(http.StatusBadRequest)
+		writeReport(w, http.StatusBadRequest, &Report{Status: StatusShutdown})
 		return
 	}
 
-	if p.getReadiness() == readinessTrue {
-		w.WriteHeader(http.StatusOK)
+	switch p.getReadiness() {
+	case readinessPending:
+		writeReport(w, http.StatusBadRequest, &Report{Status: StatusPending})
+		return
+	case readinessFalse:
+		writeReport(w, http.StatusBadRequest, &Report{Status: StatusShutdown})
 		return
 	}
 
-	w.WriteHeader(http.StatusBadRequest)
+	report := p.Check(r.Context())
+	if report.Status != StatusReady {
+		writeReport(w, http.StatusBadRequest, report)
+		return
+	}
+	writeReport(w, http.StatusOK, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report *Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Logger.Error("write readiness report is error ", err.Error())
+	}
 }
@@ -15,12 +15,32 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-// Locker locker
+// ErrNotOwner the lock is expired or held by another owner
+var ErrNotOwner = errors.New("lock is not held by the owner")
+
+// the lock is released and extended only by its owner
+var (
+	unlockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+	extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// Locker locker, Value identifies the owner of the lock
+// and should be unique to each owner.
 type Locker struct {
 	Key        string
 	Value      string
@@ -29,28 +49,50 @@ type Locker struct {
 	Ctx        context.Context
 }
 
-// NewLocker new
+// NewLocker new, expireTime is in seconds
 func NewLocker(key, value string, expireTime time.Duration, conn redis.UniversalClient) *Locker {
 	return &Locker{Key: key, Value: value, ExpireTime: expireTime, Conn: conn, Ctx: context.Background()}
 }
 
-// Lock lock action
+// Lock take the lock if it is free, it reports whether the locker owns the lock
 func (o *Locker) Lock() (bool, error) {
-
-	_, err := o.Conn.SetNX(o.Ctx, o.Key, o.Value, o.ExpireTime*time.Second).Result()
-	if err != nil {
-		return false, err
+	ok, err := o.Conn.SetNX(o.Ctx, o.Key, o.Value, o.ExpireTime*time.Second).Result()
+	if err != nil || ok {
+		return ok, err
 	}
+	// the locker may own the lock already
 	get, err := o.Conn.Get(o.Ctx, o.Key).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
-	return get != "" && get == o.Value, nil
-
+	return get == o.Value, nil
 }
 
-// UnLock unlock
+// UnLock release the lock, it is ErrNotOwner if the lock expired
+// and the lock taken by another owner since then is kept.
 func (o *Locker) UnLock() error {
-	_, err := o.Conn.Del(o.Ctx, o.Key).Result()
-	return err
+	n, err := unlockScript.Run(o.Ctx, o.Conn, []string{o.Key}, o.Value).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// Extend reset the expiration of the lock, it is ErrNotOwner if the lock expired
+func (o *Locker) Extend() error {
+	ttl := (o.ExpireTime * time.Second).Milliseconds()
+	n, err := extendScript.Run(o.Ctx, o.Conn, []string{o.Key}, o.Value, ttl).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotOwner
+	}
+	return nil
 }
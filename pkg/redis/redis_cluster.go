@@ -25,11 +25,16 @@ var ClusterClient *redis.ClusterClient
 
 // Init Init
 func Init() error {
-	return newClient()
+	return newClient(config.Config)
 }
 
-func newClient() error {
-	client, err := redis2.NewClient(config.Config.Redis)
+// InitWith init the client with the redis of c
+func InitWith(c *config.Configs) error {
+	return newClient(c)
+}
+
+func newClient(c *config.Configs) error {
+	client, err := redis2.NewClient(c.Redis)
 	if err != nil {
 		return err
 	}
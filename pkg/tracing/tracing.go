@@ -0,0 +1,150 @@
+/*
+Copyright 2022 QuanxiangCloud Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+     http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quanxiang-cloud/cabin/tailormade/header"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/quanxiang-cloud/appcenter/pkg/config"
+)
+
+const (
+	instrumentation = "github.com/quanxiang-cloud/appcenter"
+
+	defaultEndpoint = "localhost:4318"
+)
+
+var service = "app-center"
+
+// Init set the w3c trace context propagator, and export the spans of the service
+// to the collector in config when it is enabled.
+// The returned func flushes the spans on shutdown.
+func Init(c *config.Configs, name string) (func(ctx context.Context) error, error) {
+	service = name
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	conf := c.Tracing
+	if !conf.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if conf.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := conf.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(name),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Gin start a span for each request except the ones of the skipped paths,
+// continuing the trace of the caller.
+func Gin(skip ...string) gin.HandlerFunc {
+	middleware := otelgin.Middleware(service)
+	return func(c *gin.Context) {
+		for _, path := range skip {
+			if c.Request.URL.Path == path {
+				c.Next()
+				return
+			}
+		}
+		middleware(c)
+	}
+}
+
+// MutateContext return the context of header.MutateContext carrying the span of the request,
+// the context does not end with the request.
+func MutateContext(c *gin.Context) context.Context {
+	return trace.ContextWithSpan(header.MutateContext(c), trace.SpanFromContext(c.Request.Context()))
+}
+
+// Instrument start a span for each request of the client, and propagate the trace to the server
+func Instrument(client http.Client) http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	client.Transport = otelhttp.NewTransport(next)
+	return client
+}
+
+// Start start a span of name
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End record the error on the span and end it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject return the trace context of ctx, it survives serialization
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract return ctx continuing the trace context returned by Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
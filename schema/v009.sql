@@ -0,0 +1,11 @@
+create table t_app_purge
+(
+    app_id      varchar(64) not null
+        primary key,
+    steps       int         null comment 'bits of the finished teardown steps',
+    retry       int         null,
+    last_error  text        null,
+    create_time bigint      null,
+    update_time bigint      null
+)
+    comment 'progress of hard deleting apps in the recycle bin';
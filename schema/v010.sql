@@ -0,0 +1,11 @@
+create table t_app_tombstone
+(
+    app_id      varchar(64) not null
+        primary key,
+    admins      json        null comment 'user ids of t_app_user_relation',
+    scopes      json        null comment 'rows of t_app_scope',
+    admin_cache json        null comment 'user ids of the redis admin hash',
+    delete_by   varchar(64) null,
+    create_time bigint      null
+)
+    comment 'access configuration of the apps in the recycle bin';
@@ -0,0 +1,24 @@
+create table t_app_audit
+(
+    id           varchar(64) not null
+        primary key,
+    tenant_id    varchar(64) null,
+    actor_id     varchar(64) null comment 'User-Id of the request',
+    actor_name   varchar(80) null comment 'User-Name of the request',
+    action       varchar(64) null,
+    target_type  varchar(32) null comment 'app|template',
+    target_id    varchar(64) null,
+    before_value json        null comment 'fields changed by the action, before it',
+    after_value  json        null comment 'fields changed by the action, after it',
+    create_time  bigint      null
+)
+    comment 'append-only audit log of the mutations of app-center';
+
+create index idx_app_audit_target
+    on t_app_audit (target_type, target_id, create_time);
+
+create index idx_app_audit_actor
+    on t_app_audit (actor_id, create_time);
+
+create index idx_app_audit_time
+    on t_app_audit (create_time);
@@ -0,0 +1,20 @@
+create table t_app_outbox
+(
+    id           bigint auto_increment
+        primary key comment 'order of the events',
+    event_id     varchar(64)  not null,
+    event_type   varchar(64)  null,
+    app_id       varchar(64)  null,
+    data         json         null,
+    status       tinyint      default 0 not null comment '0 pending, 1 relayed, 2 given up',
+    retry        int          default 0 not null,
+    last_error   text         null,
+    create_time  bigint       null,
+    relay_time   bigint       null,
+    constraint uk_app_outbox_event
+        unique (event_id)
+)
+    comment 'domain events of the apps written in the transactions of the changes, relayed to the transports';
+
+create index idx_app_outbox_status
+    on t_app_outbox (status, id);
@@ -0,0 +1,45 @@
+create table t_app_webhook
+(
+    id          varchar(64)  not null
+        primary key,
+    app_id      varchar(64)  not null,
+    url         varchar(512) not null,
+    secret      varchar(128) null comment 'key of the hmac-sha256 signature of the deliveries',
+    events      json         null comment 'types of the events subscribed',
+    enabled     tinyint      default 1 not null,
+    create_by   varchar(64)  null,
+    create_time bigint       null,
+    update_by   varchar(64)  null,
+    update_time bigint       null
+)
+    comment 'outbound webhooks of the apps';
+
+create index idx_app_webhook_app
+    on t_app_webhook (app_id);
+
+create table t_app_webhook_delivery
+(
+    id            varchar(64) not null
+        primary key,
+    webhook_id    varchar(64) not null,
+    app_id        varchar(64) not null,
+    event_id      varchar(64) not null,
+    event_type    varchar(64) null,
+    payload       json        null comment 'the event posted',
+    status        tinyint     default 0 not null comment '0 pending, 1 delivered, 2 failed',
+    attempts      int         default 0 not null,
+    response_code int         null,
+    last_error    text        null,
+    next_time     bigint      null comment 'unix seconds of the next attempt',
+    create_time   bigint      null,
+    update_time   bigint      null,
+    constraint uk_app_webhook_delivery
+        unique (webhook_id, event_id)
+)
+    comment 'deliveries of the events to the webhooks of the apps';
+
+create index idx_app_webhook_delivery_next
+    on t_app_webhook_delivery (status, next_time);
+
+create index idx_app_webhook_delivery_hook
+    on t_app_webhook_delivery (webhook_id, create_time);
@@ -0,0 +1,15 @@
+create table t_app_status_history
+(
+    id          bigint auto_increment
+        primary key comment 'order of the transitions',
+    app_id      varchar(64) not null,
+    from_status int         null comment '0 when the app is created',
+    to_status   int         null,
+    action      varchar(64) null comment 'what moved the app to the status',
+    update_by   varchar(64) null,
+    create_time bigint      null
+)
+    comment 'transitions of the use status of the apps';
+
+create index idx_app_status_history_app
+    on t_app_status_history (app_id, id);
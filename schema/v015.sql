@@ -0,0 +1,21 @@
+create table t_app_schedule
+(
+    id           varchar(64) not null
+        primary key,
+    app_id       varchar(64) not null,
+    use_status   int         not null comment 'the use status the app moves to',
+    execute_time bigint      not null comment 'unix seconds the schedule is due',
+    status       tinyint     default 0 not null comment '0 pending, 1 running, 2 executed, 3 failed, 4 canceled',
+    last_error   text        null,
+    create_by    varchar(64) null,
+    create_time  bigint      null,
+    update_by    varchar(64) null,
+    update_time  bigint      null
+)
+    comment 'scheduled changes of the use status of the apps';
+
+create index idx_app_schedule_app
+    on t_app_schedule (app_id, execute_time);
+
+create index idx_app_schedule_due
+    on t_app_schedule (status, execute_time);
@@ -0,0 +1 @@
+ALTER TABLE t_app_center ADD COLUMN init_bits INT DEFAULT 0 COMMENT 'modules of the last initialization of app, 0 before it is recorded' AFTER server;